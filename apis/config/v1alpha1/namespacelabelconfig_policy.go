@@ -0,0 +1,484 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// ReservedKeys are maintained by Kubernetes itself and can never be set by tenants,
+// regardless of the protected domains of the policy
+var ReservedKeys = []string{
+	"kubernetes.io/metadata.name",
+}
+
+// GetClusterConfig fetches the cluster NamespacelabelConfig using the given client.
+// An empty config is returned if the cluster has no NamespacelabelConfig, so that
+// callers can always evaluate the policy
+func GetClusterConfig(ctx context.Context, c client.Reader) (*NamespacelabelConfig, error) {
+	config := &NamespacelabelConfig{}
+	if err := c.Get(ctx, types.NamespacedName{Name: ClusterConfigName}, config); err != nil {
+		if errors.IsNotFound(err) {
+			return &NamespacelabelConfig{}, nil
+		}
+		return nil, err
+	}
+
+	return config, nil
+}
+
+// CheckLabel returns an error if the policy does not allow tenants to set the given label
+func (s *NamespacelabelConfigSpec) CheckLabel(key string, value string) error {
+	for _, reserved := range ReservedKeys {
+		if key == reserved {
+			return fmt.Errorf("label key %s is reserved", key)
+		}
+	}
+
+	if dom := s.protectedDomain(key); dom != "" {
+		return fmt.Errorf("setting labels of the %s domain is not allowed", dom)
+	}
+
+	// a malformed deny pattern denies every key rather than none, so a typo cannot open up the policy
+	for _, pattern := range s.DeniedKeyPatterns {
+		if matched, err := matchPattern(PatternTypeGlob, pattern, key); err != nil {
+			return fmt.Errorf("label key %s is denied since the denied pattern %s is malformed: %v", key, pattern, err)
+		} else if matched {
+			return fmt.Errorf("label key %s matches the denied pattern %s", key, pattern)
+		}
+	}
+
+	for _, rule := range s.DenyList {
+		if matched, err := rule.match(key, value); err != nil {
+			return fmt.Errorf("label %s=%s is denied since the denied rule %s is malformed: %v", key, value, rule, err)
+		} else if matched {
+			return fmt.Errorf("label %s=%s matches the denied rule %s", key, value, rule)
+		}
+	}
+
+	if len(s.AllowedKeyPatterns) == 0 && len(s.AllowList) == 0 {
+		return nil
+	}
+
+	// malformed allow patterns match nothing
+	for _, pattern := range s.AllowedKeyPatterns {
+		if matched, _ := matchPattern(PatternTypeGlob, pattern, key); matched {
+			return nil
+		}
+	}
+
+	// a key allowed only with other values is reported as a bad value rather than a bad key
+	var keyRules []string
+	for _, rule := range s.AllowList {
+		if matched, _ := matchPattern(rule.Type, rule.Key, key); !matched {
+			continue
+		}
+		if matched, _ := rule.match(key, value); matched {
+			return nil
+		}
+		keyRules = append(keyRules, rule.String())
+	}
+	if len(keyRules) > 0 {
+		return fmt.Errorf("label value %s of key %s does not match any of the allowed rules %s", value, key, strings.Join(keyRules, ", "))
+	}
+
+	return fmt.Errorf("label key %s does not match any of the allowed patterns", key)
+}
+
+// CheckKeyPermission returns an error if the key matches a permission of the policy
+// and the user is not granted any of the matching permissions
+func (s *NamespacelabelConfigSpec) CheckKeyPermission(key string, user authenticationv1.UserInfo) error {
+	var keyPatterns []string
+	for _, permission := range s.KeyPermissions {
+		// a malformed pattern restricts every key to the subjects of the permission
+		if matched, err := matchPattern(permission.Type, permission.Key, key); err == nil && !matched {
+			continue
+		}
+		if permission.grants(user) {
+			return nil
+		}
+		keyPatterns = append(keyPatterns, permission.Key)
+	}
+
+	if len(keyPatterns) > 0 {
+		return fmt.Errorf("user %s may not set key %s, it is restricted by %s", user.Username, key, strings.Join(keyPatterns, ", "))
+	}
+
+	return nil
+}
+
+// ValidateLabels checks every label against the policy and returns an error for each
+// violation, so all of them can be reported at once
+func (s *NamespacelabelConfigSpec) ValidateLabels(fldPath *field.Path, labels map[string]string) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	keys := make([]string, 0, len(labels))
+	for key := range labels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		if err := s.CheckLabel(key, labels[key]); err != nil {
+			allErrs = append(allErrs, field.Forbidden(fldPath.Key(key), err.Error()))
+		}
+	}
+
+	return allErrs
+}
+
+// ValidateAnnotations checks every annotation against the policy like ValidateLabels, and rejects
+// the parent annotation since it would let the namespace inherit the labels of any other namespace
+func (s *NamespacelabelConfigSpec) ValidateAnnotations(fldPath *field.Path, annotations map[string]string) field.ErrorList {
+	allErrs := s.ValidateLabels(fldPath, annotations)
+
+	if _, ok := annotations[s.ParentAnnotationKey()]; ok {
+		allErrs = append(allErrs, field.Forbidden(fldPath.Key(s.ParentAnnotationKey()), "the parent annotation of the cluster policy can not be set"))
+	}
+
+	return allErrs
+}
+
+// protectedDomain returns the protected domain the key belongs to, or an empty string.
+// The prefix of the key must be the domain itself or one of its subdomains, a key
+// without a prefix is checked as a whole so bare domains cannot be set either
+func (s *NamespacelabelConfigSpec) protectedDomain(key string) string {
+	prefix := key
+	if i := strings.Index(key, "/"); i >= 0 {
+		prefix = key[:i]
+	}
+
+	for _, dom := range s.ProtectedDomains {
+		dom = strings.TrimPrefix(dom, ".")
+		if prefix == dom || strings.HasSuffix(prefix, "."+dom) {
+			return dom
+		}
+	}
+
+	return ""
+}
+
+// LimitsFor returns the limits that apply in the namespace, the cluster-wide limits
+// with the limits set in an override of the namespace replacing them
+func (s *NamespacelabelConfigSpec) LimitsFor(namespace string) NamespacelabelConfigLimits {
+	limits := s.Limits
+
+	for _, override := range s.LimitOverrides {
+		if override.Namespace != namespace {
+			continue
+		}
+		if override.Limits.MaxLabelsPerObject != nil {
+			limits.MaxLabelsPerObject = override.Limits.MaxLabelsPerObject
+		}
+		if override.Limits.MaxLabelsPerNamespace != nil {
+			limits.MaxLabelsPerNamespace = override.Limits.MaxLabelsPerNamespace
+		}
+		if override.Limits.MaxBytesPerNamespace != nil {
+			limits.MaxBytesPerNamespace = override.Limits.MaxBytesPerNamespace
+		}
+	}
+
+	return limits
+}
+
+// CheckLabelsLimit returns an error if the labels of a NamespaceLabel in the namespace
+// exceed the limits of the policy
+func (s *NamespacelabelConfigSpec) CheckLabelsLimit(namespace string, labels map[string]string) error {
+	if max := s.LimitsFor(namespace).MaxLabelsPerObject; max != nil && len(labels) > int(*max) {
+		return fmt.Errorf("requested %d labels, but at most %d are allowed", len(labels), *max)
+	}
+
+	return nil
+}
+
+// CheckNamespaceLimits returns an error for every limit of the namespace that is exceeded
+// by the labels and annotations managed on it by all sources together
+func (s *NamespacelabelConfigSpec) CheckNamespaceLimits(namespace string, labels map[string]string, annotations map[string]string) []error {
+	return s.CheckNamespaceLimitsGrowth(namespace, labels, annotations, nil, nil)
+}
+
+// CheckNamespaceLimitsGrowth returns an error for every limit of the namespace that is exceeded
+// by the managed labels and annotations and that they grow compared with the old managed keys,
+// so a namespace that is already over a lowered limit can still be updated as long as it shrinks
+func (s *NamespacelabelConfigSpec) CheckNamespaceLimitsGrowth(namespace string, labels map[string]string, annotations map[string]string,
+	oldLabels map[string]string, oldAnnotations map[string]string) []error {
+	limits := s.LimitsFor(namespace)
+	errs := []error{}
+
+	if max := limits.MaxLabelsPerNamespace; max != nil && len(labels) > int(*max) && len(labels) > len(oldLabels) {
+		errs = append(errs, fmt.Errorf("namespace %s would hold %d managed labels, but at most %d are allowed", namespace, len(labels), *max))
+	}
+
+	size := keysSize(labels, annotations)
+	if max := limits.MaxBytesPerNamespace; max != nil && size > int(*max) && size > keysSize(oldLabels, oldAnnotations) {
+		errs = append(errs, fmt.Errorf("namespace %s would hold %d bytes of managed labels and annotations, but at most %d are allowed", namespace, size, *max))
+	}
+
+	return errs
+}
+
+// keysSize returns the bytes taken by the keys and values of the labels and annotations
+func keysSize(labels map[string]string, annotations map[string]string) int {
+	size := 0
+	for key, val := range labels {
+		size += len(key) + len(val)
+	}
+	for key, val := range annotations {
+		size += len(key) + len(val)
+	}
+
+	return size
+}
+
+// ParentAnnotationKey returns the annotation naming the parent of a namespace
+func (s *NamespacelabelConfigSpec) ParentAnnotationKey() string {
+	if s.ParentAnnotation == "" {
+		return DefaultParentAnnotation
+	}
+
+	return s.ParentAnnotation
+}
+
+// NormalizeValue trims and lowercases a label value as the defaults require
+func (d *NamespacelabelConfigDefaults) NormalizeValue(value string) string {
+	if d.TrimValues {
+		value = strings.TrimSpace(value)
+	}
+	if d.LowercaseValues {
+		value = strings.ToLower(value)
+	}
+
+	return value
+}
+
+// GetRevisionHistoryLimit returns the number of revisions kept for every NamespaceLabel
+func (s *NamespacelabelConfigSpec) GetRevisionHistoryLimit() int {
+	if s.RevisionHistoryLimit == nil {
+		return DefaultRevisionHistoryLimit
+	}
+
+	return int(*s.RevisionHistoryLimit)
+}
+
+// IsTenantNamespace reports whether the tenant namespace selector selects a namespace
+// with the given labels, no namespace is selected when the selector is unset
+func (t *NamespacelabelConfigTenantRBAC) IsTenantNamespace(nsLabels map[string]string) (bool, error) {
+	if t.NamespaceSelector == nil {
+		return false, nil
+	}
+
+	selector, err := metav1.LabelSelectorAsSelector(t.NamespaceSelector)
+	if err != nil {
+		return false, err
+	}
+
+	return selector.Matches(labels.Set(nsLabels)), nil
+}
+
+// SubjectsFor returns the subjects bound in the given tenant namespace, service accounts
+// without a namespace are looked up in the tenant namespace
+func (t *NamespacelabelConfigTenantRBAC) SubjectsFor(namespace string) []rbacv1.Subject {
+	subjects := make([]rbacv1.Subject, len(t.Subjects))
+	for i, subject := range t.Subjects {
+		subject.Name = strings.ReplaceAll(subject.Name, NamespaceVariable, namespace)
+		subject.Namespace = strings.ReplaceAll(subject.Namespace, NamespaceVariable, namespace)
+		if subject.Kind == rbacv1.ServiceAccountKind && subject.Namespace == "" {
+			subject.Namespace = namespace
+		}
+		subjects[i] = subject
+	}
+
+	return subjects
+}
+
+// grants reports whether the user is one of the users, a member of one of the groups or
+// a service account of one of the namespaces of the permission
+func (p KeyPermission) grants(user authenticationv1.UserInfo) bool {
+	for _, username := range p.Users {
+		if user.Username == username {
+			return true
+		}
+	}
+
+	for _, group := range user.Groups {
+		for _, allowed := range p.Groups {
+			if group == allowed {
+				return true
+			}
+		}
+	}
+
+	// service account usernames have the form system:serviceaccount:<namespace>:<name>
+	if parts := strings.Split(user.Username, ":"); len(parts) == 4 && parts[0] == "system" && parts[1] == "serviceaccount" {
+		for _, namespace := range p.ServiceAccountNamespaces {
+			if parts[2] == namespace {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// match reports whether the label matches the rule, a rule without a value pattern
+// matches every value. An error is returned if a pattern of the rule is malformed
+func (r LabelRule) match(key string, value string) (bool, error) {
+	matched, err := matchPattern(r.Type, r.Key, key)
+	if err != nil || !matched || r.Value == "" {
+		return matched, err
+	}
+
+	return matchPattern(r.Type, r.Value, value)
+}
+
+// String returns the rule in the key=value form used in error messages
+func (r LabelRule) String() string {
+	if r.Value == "" {
+		return r.Key
+	}
+
+	return r.Key + "=" + r.Value
+}
+
+// compiledPatterns caches the compiled regex patterns by their source, the patterns of the
+// cluster policy are matched on every admission request so each is compiled only once
+var compiledPatterns sync.Map
+
+// matchPattern reports whether the string matches the glob or regex pattern,
+// regex patterns must match the whole string. An error is returned if the pattern is malformed
+func matchPattern(patternType PatternType, pattern string, s string) (bool, error) {
+	if patternType != PatternTypeRegex {
+		// the glob syntax is the one of path.Match, which also reports malformed patterns
+		if _, err := path.Match(pattern, ""); err != nil {
+			return false, err
+		}
+		pattern = globToRegexp(pattern)
+	}
+
+	re, err := compilePattern(pattern)
+	if err != nil {
+		return false, err
+	}
+
+	return re.MatchString(s), nil
+}
+
+// globToRegexp translates a well-formed glob pattern to a regex. Unlike path.Match, * and ?
+// also match a /, so a pattern such as *.example.com* matches the prefixed key team.example.com/owner
+func globToRegexp(pattern string) string {
+	var b strings.Builder
+	b.WriteString("(?s)")
+
+	inClass := false
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; {
+		case c == '\\' && i+1 < len(pattern) && pattern[i+1] == '-':
+			// an escaped - is no range inside a character class
+			i++
+			b.WriteString(`\-`)
+		case c == '\\' && i+1 < len(pattern):
+			i++
+			b.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		case inClass:
+			switch c {
+			case ']':
+				inClass = false
+				b.WriteByte(c)
+			case '[':
+				b.WriteString(`\[`)
+			default:
+				b.WriteByte(c)
+			}
+		case c == '[':
+			inClass = true
+			b.WriteByte(c)
+			if i+1 < len(pattern) && pattern[i+1] == '^' {
+				i++
+				b.WriteByte('^')
+			}
+		case c == '*':
+			b.WriteString(".*")
+		case c == '?':
+			b.WriteByte('.')
+		default:
+			b.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		}
+	}
+
+	return b.String()
+}
+
+// compilePattern returns the compiled regex pattern, anchored to match the whole string
+func compilePattern(pattern string) (*regexp.Regexp, error) {
+	if re, ok := compiledPatterns.Load(pattern); ok {
+		return re.(*regexp.Regexp), nil
+	}
+
+	re, err := regexp.Compile("^(?:" + pattern + ")$")
+	if err != nil {
+		return nil, err
+	}
+	compiledPatterns.Store(pattern, re)
+
+	return re, nil
+}
+
+// ValidatePatterns returns an error for every malformed glob or regex pattern of the policy
+func (s *NamespacelabelConfigSpec) ValidatePatterns(fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	validate := func(fldPath *field.Path, patternType PatternType, pattern string) {
+		if _, err := matchPattern(patternType, pattern, ""); err != nil {
+			allErrs = append(allErrs, field.Invalid(fldPath, pattern, err.Error()))
+		}
+	}
+
+	for i, pattern := range s.AllowedKeyPatterns {
+		validate(fldPath.Child("allowedKeyPatterns").Index(i), PatternTypeGlob, pattern)
+	}
+	for i, pattern := range s.DeniedKeyPatterns {
+		validate(fldPath.Child("deniedKeyPatterns").Index(i), PatternTypeGlob, pattern)
+	}
+	for i, rule := range s.AllowList {
+		validate(fldPath.Child("allowList").Index(i).Child("key"), rule.Type, rule.Key)
+		validate(fldPath.Child("allowList").Index(i).Child("value"), rule.Type, rule.Value)
+	}
+	for i, rule := range s.DenyList {
+		validate(fldPath.Child("denyList").Index(i).Child("key"), rule.Type, rule.Key)
+		validate(fldPath.Child("denyList").Index(i).Child("value"), rule.Type, rule.Value)
+	}
+	for i, permission := range s.KeyPermissions {
+		validate(fldPath.Child("keyPermissions").Index(i).Child("key"), permission.Type, permission.Key)
+	}
+
+	return allErrs
+}
@@ -0,0 +1,374 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"reflect"
+	"testing"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/utils/pointer"
+)
+
+func TestCheckLabel(t *testing.T) {
+	spec := NamespacelabelConfigSpec{
+		ProtectedDomains:   []string{"kubernetes.io"},
+		AllowedKeyPatterns: []string{"tenant.dana.io/*", "app", "kubernetes.io/*"},
+		DeniedKeyPatterns:  []string{"tenant.dana.io/internal-*"},
+	}
+
+	tests := map[string]bool{
+		"tenant.dana.io/team":        true,
+		"app":                        true,
+		"kubernetes.io/metadata":     false,
+		"tenant.dana.io/internal-id": false,
+		"other":                      false,
+	}
+
+	for key, allowed := range tests {
+		if err := spec.CheckLabel(key, "value"); (err == nil) != allowed {
+			t.Errorf("CheckLabel(%q) returned %v, expected allowed=%v", key, err, allowed)
+		}
+	}
+}
+
+func TestCheckLabelProtectedDomains(t *testing.T) {
+	spec := NamespacelabelConfigSpec{
+		ProtectedDomains: []string{"kubernetes.io"},
+	}
+
+	tests := map[string]bool{
+		"kubernetes.io/name":          false,
+		"node.kubernetes.io/role":     false,
+		"kubernetes.io":               false,
+		"sub.kubernetes.io":           false,
+		"notkubernetes.io/name":       true,
+		"kubernetes.io.example.com/x": true,
+		"app":                         true,
+	}
+
+	for key, allowed := range tests {
+		if err := spec.CheckLabel(key, "value"); (err == nil) != allowed {
+			t.Errorf("CheckLabel(%q) returned %v, expected allowed=%v", key, err, allowed)
+		}
+	}
+}
+
+func TestCheckLabelReservedKeys(t *testing.T) {
+	spec := NamespacelabelConfigSpec{}
+
+	if err := spec.CheckLabel("kubernetes.io/metadata.name", "value"); err == nil {
+		t.Error("expected reserved keys to be denied without protected domains")
+	}
+}
+
+func TestCheckLabelRules(t *testing.T) {
+	spec := NamespacelabelConfigSpec{
+		AllowList: []LabelRule{
+			{Key: "env", Value: "dev|staging|prod", Type: PatternTypeRegex},
+			{Key: "team-*"},
+		},
+		DenyList: []LabelRule{
+			{Key: "team-[0-9]+", Type: PatternTypeRegex},
+			{Key: "team-*", Value: "root"},
+		},
+	}
+
+	tests := []struct {
+		key     string
+		value   string
+		allowed bool
+	}{
+		{"env", "prod", true},
+		{"env", "production", false},
+		{"team-a", "x", true},
+		{"team-42", "x", false},
+		{"team-a", "root", false},
+		{"other", "x", false},
+	}
+
+	for _, test := range tests {
+		if err := spec.CheckLabel(test.key, test.value); (err == nil) != test.allowed {
+			t.Errorf("CheckLabel(%q, %q) returned %v, expected allowed=%v", test.key, test.value, err, test.allowed)
+		}
+	}
+}
+
+func TestValidateLabels(t *testing.T) {
+	spec := NamespacelabelConfigSpec{
+		ProtectedDomains:  []string{"kubernetes.io"},
+		DeniedKeyPatterns: []string{"internal-*"},
+	}
+
+	labels := map[string]string{
+		"kubernetes.io/name": "a",
+		"internal-id":        "b",
+		"app":                "c",
+	}
+
+	errs := spec.ValidateLabels(field.NewPath("spec", "labels"), labels)
+	if len(errs) != 2 {
+		t.Fatalf("expected an error for each denied label, got %v", errs)
+	}
+	if errs[0].Field != "spec.labels[internal-id]" || errs[1].Field != "spec.labels[kubernetes.io/name]" {
+		t.Errorf("unexpected fields in errors: %v", errs)
+	}
+}
+
+func TestCheckLabelsLimit(t *testing.T) {
+	spec := NamespacelabelConfigSpec{
+		Limits: NamespacelabelConfigLimits{
+			MaxLabelsPerObject: pointer.Int32(1),
+		},
+	}
+
+	if err := spec.CheckLabelsLimit("default", map[string]string{"a": "1"}); err != nil {
+		t.Errorf("expected labels within the limit to be allowed: %v", err)
+	}
+
+	if err := spec.CheckLabelsLimit("default", map[string]string{"a": "1", "b": "2"}); err == nil {
+		t.Error("expected labels over the limit to be denied")
+	}
+}
+
+func TestCheckNamespaceLimits(t *testing.T) {
+	spec := NamespacelabelConfigSpec{
+		Limits: NamespacelabelConfigLimits{
+			MaxLabelsPerNamespace: pointer.Int32(2),
+			MaxBytesPerNamespace:  pointer.Int32(12),
+		},
+		LimitOverrides: []NamespacelabelConfigLimitOverride{
+			{
+				Namespace: "big",
+				Limits: NamespacelabelConfigLimits{
+					MaxLabelsPerNamespace: pointer.Int32(3),
+				},
+			},
+		},
+	}
+
+	labels := map[string]string{"a": "1", "b": "2", "c": "3"}
+	annotations := map[string]string{"note": "x"}
+
+	if errs := spec.CheckNamespaceLimits("default", labels, annotations); len(errs) != 1 {
+		t.Errorf("expected the label limit to be exceeded, got %v", errs)
+	}
+
+	// the override raises the label limit but keeps the cluster-wide size limit
+	if errs := spec.CheckNamespaceLimits("big", labels, annotations); len(errs) != 0 {
+		t.Errorf("expected the override to allow the labels, got %v", errs)
+	}
+	annotations["note"] = "longer"
+	if errs := spec.CheckNamespaceLimits("big", labels, annotations); len(errs) != 1 {
+		t.Errorf("expected the size limit to be exceeded, got %v", errs)
+	}
+
+	// an override of zero is a limit of its own rather than an unset one
+	spec.LimitOverrides = append(spec.LimitOverrides, NamespacelabelConfigLimitOverride{
+		Namespace: "frozen",
+		Limits:    NamespacelabelConfigLimits{MaxLabelsPerNamespace: pointer.Int32(0)},
+	})
+	if errs := spec.CheckNamespaceLimits("frozen", map[string]string{"a": "1"}, nil); len(errs) != 1 {
+		t.Errorf("expected the zero override to deny every label, got %v", errs)
+	}
+}
+
+func TestCheckNamespaceLimitsGrowth(t *testing.T) {
+	spec := NamespacelabelConfigSpec{
+		Limits: NamespacelabelConfigLimits{
+			MaxLabelsPerNamespace: pointer.Int32(2),
+			MaxBytesPerNamespace:  pointer.Int32(8),
+		},
+	}
+
+	oldLabels := map[string]string{"a": "1", "b": "2", "c": "3", "d": "4"}
+
+	// a namespace over its limits may shrink without getting under them
+	labels := map[string]string{"a": "1", "b": "2", "c": "3"}
+	if errs := spec.CheckNamespaceLimitsGrowth("default", labels, nil, oldLabels, nil); len(errs) != 0 {
+		t.Errorf("expected an update shrinking the keys to be allowed, got %v", errs)
+	}
+
+	// changing a value keeps the count but grows the size
+	labels = map[string]string{"a": "1", "b": "2", "c": "3", "d": "longer"}
+	if errs := spec.CheckNamespaceLimitsGrowth("default", labels, nil, oldLabels, nil); len(errs) != 1 {
+		t.Errorf("expected the size limit to be exceeded, got %v", errs)
+	}
+
+	labels = map[string]string{"a": "1", "b": "2", "c": "3", "d": "4", "e": "5"}
+	if errs := spec.CheckNamespaceLimitsGrowth("default", labels, nil, oldLabels, nil); len(errs) != 2 {
+		t.Errorf("expected both limits to be exceeded, got %v", errs)
+	}
+}
+
+func TestCheckKeyPermission(t *testing.T) {
+	spec := NamespacelabelConfigSpec{
+		KeyPermissions: []KeyPermission{
+			{Key: "cost-center/*", Groups: []string{"finops"}},
+			{Key: "ci/.+", Type: PatternTypeRegex, ServiceAccountNamespaces: []string{"ci"}, Users: []string{"alice"}},
+		},
+	}
+
+	tests := []struct {
+		key     string
+		user    authenticationv1.UserInfo
+		allowed bool
+	}{
+		{"cost-center/id", authenticationv1.UserInfo{Username: "bob", Groups: []string{"finops"}}, true},
+		{"cost-center/id", authenticationv1.UserInfo{Username: "bob", Groups: []string{"tenants"}}, false},
+		{"ci/pipeline", authenticationv1.UserInfo{Username: "system:serviceaccount:ci:builder"}, true},
+		{"ci/pipeline", authenticationv1.UserInfo{Username: "system:serviceaccount:team-a:builder"}, false},
+		{"ci/pipeline", authenticationv1.UserInfo{Username: "alice"}, true},
+		{"app", authenticationv1.UserInfo{Username: "bob"}, true},
+	}
+
+	for _, test := range tests {
+		if err := spec.CheckKeyPermission(test.key, test.user); (err == nil) != test.allowed {
+			t.Errorf("CheckKeyPermission(%q, %q) returned %v, expected allowed=%v", test.key, test.user.Username, err, test.allowed)
+		}
+	}
+}
+
+func TestIsTenantNamespace(t *testing.T) {
+	unset := NamespacelabelConfigTenantRBAC{}
+	if isTenant, err := unset.IsTenantNamespace(map[string]string{"tenant": "true"}); err != nil || isTenant {
+		t.Errorf("IsTenantNamespace without a selector returned %v, %v, expected false", isTenant, err)
+	}
+
+	tenantRBAC := NamespacelabelConfigTenantRBAC{
+		NamespaceSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"tenant": "true"}},
+	}
+	tests := map[string]bool{
+		"true":  true,
+		"false": false,
+	}
+
+	for val, expected := range tests {
+		if isTenant, err := tenantRBAC.IsTenantNamespace(map[string]string{"tenant": val}); err != nil || isTenant != expected {
+			t.Errorf("IsTenantNamespace(tenant=%s) returned %v, %v, expected %v", val, isTenant, err, expected)
+		}
+	}
+}
+
+func TestGlobPatternsMatchPrefixedKeys(t *testing.T) {
+	spec := NamespacelabelConfigSpec{
+		DeniedKeyPatterns: []string{"*.example.com*"},
+	}
+
+	// a * also matches the / between the prefix and the name of a key
+	for _, key := range []string{"team.example.com/owner", "a.example.com"} {
+		if err := spec.CheckLabel(key, "x"); err == nil {
+			t.Errorf("expected %s to be denied", key)
+		}
+	}
+	if err := spec.CheckLabel("example.com/owner", "x"); err != nil {
+		t.Errorf("expected example.com/owner to be allowed: %v", err)
+	}
+
+	for pattern, matches := range map[string]map[string]bool{
+		"tenant.dana.io/*": {"tenant.dana.io/team": true, "tenant.dana.io": false},
+		"team-[a-c]?":      {"team-b1": true, "team-d1": false},
+		"team-[^a]":        {"team-b": true, "team-a": false},
+		"a\\-[x\\-]":       {"a-x": true, "a--": true, "a-y": false},
+		"team?owner":       {"team/owner": true, "team-owner": true, "teamowner": false},
+	} {
+		for s, expected := range matches {
+			if matched, err := matchPattern(PatternTypeGlob, pattern, s); err != nil || matched != expected {
+				t.Errorf("matchPattern(%q, %q) = %v, %v, expected %v", pattern, s, matched, err, expected)
+			}
+		}
+	}
+}
+
+func TestMalformedPatterns(t *testing.T) {
+	spec := NamespacelabelConfigSpec{
+		DeniedKeyPatterns: []string{"team-[a"},
+		DenyList: []LabelRule{
+			{Key: "env", Value: "(prod", Type: PatternTypeRegex},
+		},
+		AllowList: []LabelRule{
+			{Key: "tier[", Type: PatternTypeGlob},
+		},
+		KeyPermissions: []KeyPermission{
+			{Key: "*(", Type: PatternTypeRegex, Groups: []string{"admins"}},
+		},
+	}
+
+	// malformed deny patterns deny every key and malformed allow patterns allow none
+	if err := spec.CheckLabel("tier", "gold"); err == nil {
+		t.Error("expected a policy with malformed deny patterns to deny the label")
+	}
+	spec.DeniedKeyPatterns = nil
+	spec.DenyList = nil
+	if err := spec.CheckLabel("tier", "gold"); err == nil {
+		t.Error("expected a malformed allow pattern not to allow the label")
+	}
+
+	// a malformed permission restricts every key
+	if err := spec.CheckKeyPermission("tier", authenticationv1.UserInfo{Username: "alice"}); err == nil {
+		t.Error("expected a malformed permission to restrict the key")
+	}
+	if err := spec.CheckKeyPermission("tier", authenticationv1.UserInfo{Username: "bob", Groups: []string{"admins"}}); err != nil {
+		t.Errorf("expected the subjects of a malformed permission to be granted the key: %v", err)
+	}
+}
+
+func TestValidatePatterns(t *testing.T) {
+	spec := NamespacelabelConfigSpec{
+		AllowedKeyPatterns: []string{"tenant.dana.io/*"},
+		DeniedKeyPatterns:  []string{"team-[a"},
+		DenyList: []LabelRule{
+			{Key: "env", Value: "(prod", Type: PatternTypeRegex},
+		},
+		KeyPermissions: []KeyPermission{
+			{Key: "cost-center", Type: PatternTypeGlob},
+			{Key: "*(", Type: PatternTypeRegex},
+		},
+	}
+
+	errs := spec.ValidatePatterns(field.NewPath("spec"))
+	fields := []string{}
+	for _, err := range errs {
+		fields = append(fields, err.Field)
+	}
+	expectedFields := []string{"spec.deniedKeyPatterns[0]", "spec.denyList[0].value", "spec.keyPermissions[1].key"}
+	if !reflect.DeepEqual(fields, expectedFields) {
+		t.Errorf("ValidatePatterns reported fields %v, expected %v", fields, expectedFields)
+	}
+}
+
+func TestValidateAnnotations(t *testing.T) {
+	spec := NamespacelabelConfigSpec{}
+	annotations := map[string]string{DefaultParentAnnotation: "team-b", "description": "web"}
+
+	errs := spec.ValidateAnnotations(field.NewPath("spec", "annotations"), annotations)
+	if len(errs) != 1 || errs[0].Field != "spec.annotations[dana.io/parent]" {
+		t.Errorf("expected only the parent annotation to be denied, got %v", errs)
+	}
+
+	// the parent annotation configured by the policy is denied instead of the default
+	spec.ParentAnnotation = "example.com/parent"
+	if errs := spec.ValidateAnnotations(field.NewPath("spec", "annotations"), annotations); len(errs) != 0 {
+		t.Errorf("expected the default parent annotation to be allowed, got %v", errs)
+	}
+	annotations["example.com/parent"] = "team-b"
+	if errs := spec.ValidateAnnotations(field.NewPath("spec", "annotations"), annotations); len(errs) != 1 {
+		t.Errorf("expected the configured parent annotation to be denied, got %v", errs)
+	}
+}
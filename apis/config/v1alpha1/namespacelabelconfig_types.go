@@ -0,0 +1,242 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ClusterConfigName is the name of the NamespacelabelConfig object that holds the cluster policy
+const ClusterConfigName = "cluster"
+
+// NamespacelabelConfigSpec defines the desired state of NamespacelabelConfig
+type NamespacelabelConfigSpec struct {
+	// List of label domains that are used for management and cannot be set by tenants
+	ProtectedDomains []string `json:"protectedDomains,omitempty"`
+
+	// List of glob patterns of label keys tenants are allowed to set,
+	// when empty every key that is not denied is allowed
+	AllowedKeyPatterns []string `json:"allowedKeyPatterns,omitempty"`
+
+	// List of glob patterns of label keys tenants are not allowed to set
+	DeniedKeyPatterns []string `json:"deniedKeyPatterns,omitempty"`
+
+	// List of rules of which labels tenants are allowed to set, when empty together with
+	// AllowedKeyPatterns every label that is not denied is allowed
+	AllowList []LabelRule `json:"allowList,omitempty"`
+
+	// List of rules of which labels tenants are not allowed to set
+	DenyList []LabelRule `json:"denyList,omitempty"`
+
+	// List of permissions restricting who may set matching label and annotation keys,
+	// keys that match no permission may be set by everyone
+	KeyPermissions []KeyPermission `json:"keyPermissions,omitempty"`
+
+	// Require RBAC permission on the virtual namespacelabels/keys resource, with the key as the
+	// resource name, for every key a user adds (create), changes (update) or removes (delete)
+	AuthorizeKeys bool `json:"authorizeKeys,omitempty"`
+
+	// List of groups whose members may change keys owned by a NamespaceLabel directly on the namespace
+	NamespaceEditorGroups []string `json:"namespaceEditorGroups,omitempty"`
+
+	// List of groups whose members may change immutable keys and edit or delete locked NamespaceLabel objects
+	AdminGroups []string `json:"adminGroups,omitempty"`
+
+	// Limits on the labels a NamespaceLabel can request and a namespace can hold
+	Limits NamespacelabelConfigLimits `json:"limits,omitempty"`
+
+	// List of limits that replace the cluster-wide limits in specific namespaces
+	LimitOverrides []NamespacelabelConfigLimitOverride `json:"limitOverrides,omitempty"`
+
+	// Defaults applied to the labels of NamespaceLabel objects when they are admitted
+	Defaults NamespacelabelConfigDefaults `json:"defaults,omitempty"`
+
+	// What the webhook does with a NamespaceLabel requesting a key that another NamespaceLabel
+	// or ClusterNamespaceLabel sets to a different value on the same namespace, defaults to Warn
+	// +kubebuilder:default=Warn
+	// +optional
+	KeyConflicts KeyConflictAction `json:"keyConflicts,omitempty"`
+
+	// Annotation of a namespace naming its parent namespace, the descendants of a namespace inherit
+	// the labels propagated by its NamespaceLabels. It can not be set by a NamespaceLabel or
+	// ClusterNamespaceLabel. Defaults to dana.io/parent
+	// +optional
+	ParentAnnotation string `json:"parentAnnotation,omitempty"`
+
+	// RBAC provisioned in tenant namespaces so that tenants can use the NamespaceLabel CRD
+	TenantRBAC NamespacelabelConfigTenantRBAC `json:"tenantRBAC,omitempty"`
+
+	// Number of revisions kept for every NamespaceLabel, older revisions are deleted.
+	// Zero disables the history. Defaults to 10
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	RevisionHistoryLimit *int32 `json:"revisionHistoryLimit,omitempty"`
+}
+
+// NamespacelabelConfigTenantRBAC defines which namespaces are tenant namespaces and who is bound in them
+type NamespacelabelConfigTenantRBAC struct {
+	// Selector of the tenant namespaces, when unset no namespace is provisioned
+	NamespaceSelector *metav1.LabelSelector `json:"namespaceSelector,omitempty"`
+
+	// List of subjects bound to the tenant ClusterRole in every tenant namespace,
+	// $(NAMESPACE) in a subject name is replaced with the name of the namespace
+	Subjects []rbacv1.Subject `json:"subjects,omitempty"`
+}
+
+// DefaultParentAnnotation is the annotation naming the parent of a namespace when the policy sets none
+const DefaultParentAnnotation = "dana.io/parent"
+
+// DefaultRevisionHistoryLimit is the number of revisions kept when the policy sets no limit
+const DefaultRevisionHistoryLimit = 10
+
+// NamespaceVariable is replaced with the namespace of the NamespaceLabel in default label values
+const NamespaceVariable = "$(NAMESPACE)"
+
+// NamespacelabelConfigDefaults defines how the labels requested by NamespaceLabel objects are normalized
+type NamespacelabelConfigDefaults struct {
+	// Map of labels added to every NamespaceLabel that does not set them itself,
+	// $(NAMESPACE) in a value is replaced with the namespace of the NamespaceLabel
+	Labels map[string]string `json:"labels,omitempty"`
+
+	// Prefix forced onto label keys requested without a prefix, such as tenant.dana.io
+	KeyPrefix string `json:"keyPrefix,omitempty"`
+
+	// Remove leading and trailing whitespace from label values
+	TrimValues bool `json:"trimValues,omitempty"`
+
+	// Convert label values to lowercase
+	LowercaseValues bool `json:"lowercaseValues,omitempty"`
+}
+
+// KeyConflictAction is how the webhook treats keys that collide with the keys of another source
+// +kubebuilder:validation:Enum=Warn;Reject
+type KeyConflictAction string
+
+const (
+	// KeyConflictWarn admits the NamespaceLabel and returns a warning for every colliding key
+	KeyConflictWarn KeyConflictAction = "Warn"
+	// KeyConflictReject rejects the NamespaceLabel when one of its keys collides
+	KeyConflictReject KeyConflictAction = "Reject"
+)
+
+// PatternType is the syntax of a pattern in a LabelRule
+// +kubebuilder:validation:Enum=Glob;Regex
+type PatternType string
+
+const (
+	// PatternTypeGlob matches with shell glob syntax, such as tenant.dana.io/*, where * and ? also match a /
+	PatternTypeGlob PatternType = "Glob"
+	// PatternTypeRegex matches with a regular expression that must match the whole string
+	PatternTypeRegex PatternType = "Regex"
+)
+
+// LabelRule matches labels by key and optionally by value
+type LabelRule struct {
+	// Pattern the label key must match
+	Key string `json:"key"`
+
+	// Pattern the label value must match, when empty every value matches
+	// +optional
+	Value string `json:"value,omitempty"`
+
+	// Syntax of the key and value patterns, defaults to Glob
+	// +kubebuilder:default=Glob
+	// +optional
+	Type PatternType `json:"type,omitempty"`
+}
+
+// KeyPermission restricts the keys matching a pattern to the listed users, groups and service accounts
+type KeyPermission struct {
+	// Pattern of the keys the permission applies to
+	Key string `json:"key"`
+
+	// Syntax of the key pattern, defaults to Glob
+	// +kubebuilder:default=Glob
+	// +optional
+	Type PatternType `json:"type,omitempty"`
+
+	// List of users who may set matching keys
+	Users []string `json:"users,omitempty"`
+
+	// List of groups whose members may set matching keys
+	Groups []string `json:"groups,omitempty"`
+
+	// List of namespaces whose service accounts may set matching keys
+	ServiceAccountNamespaces []string `json:"serviceAccountNamespaces,omitempty"`
+}
+
+// NamespacelabelConfigLimits defines limits on the labels requested by NamespaceLabel objects,
+// a limit that is not set is unlimited while zero allows nothing
+type NamespacelabelConfigLimits struct {
+	// Maximum number of labels a single NamespaceLabel may request
+	//+kubebuilder:validation:Minimum=0
+	// +optional
+	MaxLabelsPerObject *int32 `json:"maxLabelsPerObject,omitempty"`
+
+	// Maximum number of labels managed on a namespace by all NamespaceLabel and
+	// ClusterNamespaceLabel objects together
+	//+kubebuilder:validation:Minimum=0
+	// +optional
+	MaxLabelsPerNamespace *int32 `json:"maxLabelsPerNamespace,omitempty"`
+
+	// Maximum total size in bytes of the keys and values of the labels and annotations
+	// managed on a namespace
+	//+kubebuilder:validation:Minimum=0
+	// +optional
+	MaxBytesPerNamespace *int32 `json:"maxBytesPerNamespace,omitempty"`
+}
+
+// NamespacelabelConfigLimitOverride replaces the cluster-wide limits in a namespace,
+// only the limits that are set are replaced, so an override of zero allows nothing
+type NamespacelabelConfigLimitOverride struct {
+	// Name of the namespace the limits apply to
+	Namespace string `json:"namespace"`
+
+	// Limits of the namespace
+	Limits NamespacelabelConfigLimits `json:"limits"`
+}
+
+// NamespacelabelConfigStatus defines the observed state of NamespacelabelConfig
+type NamespacelabelConfigStatus struct {
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:resource:scope=Cluster
+
+// NamespacelabelConfig is the Schema for the namespacelabelconfigs API
+type NamespacelabelConfig struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   NamespacelabelConfigSpec   `json:"spec,omitempty"`
+	Status NamespacelabelConfigStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// NamespacelabelConfigList contains a list of NamespacelabelConfig
+type NamespacelabelConfigList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []NamespacelabelConfig `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&NamespacelabelConfig{}, &NamespacelabelConfigList{})
+}
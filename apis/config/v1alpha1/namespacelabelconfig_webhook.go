@@ -0,0 +1,69 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+)
+
+// log is for logging in this package.
+var namespacelabelconfiglog = logf.Log.WithName("namespacelabelconfig-resource")
+
+func (r *NamespacelabelConfig) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(r).
+		Complete()
+}
+
+//+kubebuilder:webhook:path=/validate-config-dana-io-v1alpha1-namespacelabelconfig,mutating=false,failurePolicy=fail,sideEffects=None,groups=config.dana.io,resources=namespacelabelconfigs,verbs=create;update,versions=v1alpha1,name=vnamespacelabelconfig.kb.io,admissionReviewVersions=v1
+
+var _ webhook.Validator = &NamespacelabelConfig{}
+
+// ValidateCreate implements webhook.Validator so a webhook will be registered for the type
+func (r *NamespacelabelConfig) ValidateCreate() error {
+	namespacelabelconfiglog.Info("validate create", "name", r.Name)
+
+	return r.validatePatterns()
+}
+
+// ValidateUpdate implements webhook.Validator so a webhook will be registered for the type
+func (r *NamespacelabelConfig) ValidateUpdate(old runtime.Object) error {
+	namespacelabelconfiglog.Info("validate update", "name", r.Name)
+
+	return r.validatePatterns()
+}
+
+// ValidateDelete implements webhook.Validator so a webhook will be registered for the type
+func (r *NamespacelabelConfig) ValidateDelete() error {
+	return nil
+}
+
+// validatePatterns rejects a policy with malformed patterns, which would otherwise
+// deny every key or allow none
+func (r *NamespacelabelConfig) validatePatterns() error {
+	allErrs := r.Spec.ValidatePatterns(field.NewPath("spec"))
+	if len(allErrs) == 0 {
+		return nil
+	}
+
+	return apierrors.NewInvalid(GroupVersion.WithKind("NamespacelabelConfig").GroupKind(), r.Name, allErrs)
+}
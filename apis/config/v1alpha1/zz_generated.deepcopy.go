@@ -0,0 +1,312 @@
+//go:build !ignore_autogenerated
+// +build !ignore_autogenerated
+
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Code generated by controller-gen. DO NOT EDIT.
+
+package v1alpha1
+
+import (
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KeyPermission) DeepCopyInto(out *KeyPermission) {
+	*out = *in
+	if in.Users != nil {
+		in, out := &in.Users, &out.Users
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Groups != nil {
+		in, out := &in.Groups, &out.Groups
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.ServiceAccountNamespaces != nil {
+		in, out := &in.ServiceAccountNamespaces, &out.ServiceAccountNamespaces
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KeyPermission.
+func (in *KeyPermission) DeepCopy() *KeyPermission {
+	if in == nil {
+		return nil
+	}
+	out := new(KeyPermission)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LabelRule) DeepCopyInto(out *LabelRule) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LabelRule.
+func (in *LabelRule) DeepCopy() *LabelRule {
+	if in == nil {
+		return nil
+	}
+	out := new(LabelRule)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NamespacelabelConfig) DeepCopyInto(out *NamespacelabelConfig) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	out.Status = in.Status
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NamespacelabelConfig.
+func (in *NamespacelabelConfig) DeepCopy() *NamespacelabelConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(NamespacelabelConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *NamespacelabelConfig) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NamespacelabelConfigDefaults) DeepCopyInto(out *NamespacelabelConfigDefaults) {
+	*out = *in
+	if in.Labels != nil {
+		in, out := &in.Labels, &out.Labels
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NamespacelabelConfigDefaults.
+func (in *NamespacelabelConfigDefaults) DeepCopy() *NamespacelabelConfigDefaults {
+	if in == nil {
+		return nil
+	}
+	out := new(NamespacelabelConfigDefaults)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NamespacelabelConfigLimitOverride) DeepCopyInto(out *NamespacelabelConfigLimitOverride) {
+	*out = *in
+	in.Limits.DeepCopyInto(&out.Limits)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NamespacelabelConfigLimitOverride.
+func (in *NamespacelabelConfigLimitOverride) DeepCopy() *NamespacelabelConfigLimitOverride {
+	if in == nil {
+		return nil
+	}
+	out := new(NamespacelabelConfigLimitOverride)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NamespacelabelConfigLimits) DeepCopyInto(out *NamespacelabelConfigLimits) {
+	*out = *in
+	if in.MaxLabelsPerObject != nil {
+		in, out := &in.MaxLabelsPerObject, &out.MaxLabelsPerObject
+		*out = new(int32)
+		**out = **in
+	}
+	if in.MaxLabelsPerNamespace != nil {
+		in, out := &in.MaxLabelsPerNamespace, &out.MaxLabelsPerNamespace
+		*out = new(int32)
+		**out = **in
+	}
+	if in.MaxBytesPerNamespace != nil {
+		in, out := &in.MaxBytesPerNamespace, &out.MaxBytesPerNamespace
+		*out = new(int32)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NamespacelabelConfigLimits.
+func (in *NamespacelabelConfigLimits) DeepCopy() *NamespacelabelConfigLimits {
+	if in == nil {
+		return nil
+	}
+	out := new(NamespacelabelConfigLimits)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NamespacelabelConfigList) DeepCopyInto(out *NamespacelabelConfigList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]NamespacelabelConfig, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NamespacelabelConfigList.
+func (in *NamespacelabelConfigList) DeepCopy() *NamespacelabelConfigList {
+	if in == nil {
+		return nil
+	}
+	out := new(NamespacelabelConfigList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *NamespacelabelConfigList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NamespacelabelConfigSpec) DeepCopyInto(out *NamespacelabelConfigSpec) {
+	*out = *in
+	if in.ProtectedDomains != nil {
+		in, out := &in.ProtectedDomains, &out.ProtectedDomains
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.AllowedKeyPatterns != nil {
+		in, out := &in.AllowedKeyPatterns, &out.AllowedKeyPatterns
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.DeniedKeyPatterns != nil {
+		in, out := &in.DeniedKeyPatterns, &out.DeniedKeyPatterns
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.AllowList != nil {
+		in, out := &in.AllowList, &out.AllowList
+		*out = make([]LabelRule, len(*in))
+		copy(*out, *in)
+	}
+	if in.DenyList != nil {
+		in, out := &in.DenyList, &out.DenyList
+		*out = make([]LabelRule, len(*in))
+		copy(*out, *in)
+	}
+	if in.KeyPermissions != nil {
+		in, out := &in.KeyPermissions, &out.KeyPermissions
+		*out = make([]KeyPermission, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.NamespaceEditorGroups != nil {
+		in, out := &in.NamespaceEditorGroups, &out.NamespaceEditorGroups
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.AdminGroups != nil {
+		in, out := &in.AdminGroups, &out.AdminGroups
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	in.Limits.DeepCopyInto(&out.Limits)
+	if in.LimitOverrides != nil {
+		in, out := &in.LimitOverrides, &out.LimitOverrides
+		*out = make([]NamespacelabelConfigLimitOverride, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	in.Defaults.DeepCopyInto(&out.Defaults)
+	in.TenantRBAC.DeepCopyInto(&out.TenantRBAC)
+	if in.RevisionHistoryLimit != nil {
+		in, out := &in.RevisionHistoryLimit, &out.RevisionHistoryLimit
+		*out = new(int32)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NamespacelabelConfigSpec.
+func (in *NamespacelabelConfigSpec) DeepCopy() *NamespacelabelConfigSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(NamespacelabelConfigSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NamespacelabelConfigStatus) DeepCopyInto(out *NamespacelabelConfigStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NamespacelabelConfigStatus.
+func (in *NamespacelabelConfigStatus) DeepCopy() *NamespacelabelConfigStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(NamespacelabelConfigStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NamespacelabelConfigTenantRBAC) DeepCopyInto(out *NamespacelabelConfigTenantRBAC) {
+	*out = *in
+	if in.NamespaceSelector != nil {
+		in, out := &in.NamespaceSelector, &out.NamespaceSelector
+		*out = new(v1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Subjects != nil {
+		in, out := &in.Subjects, &out.Subjects
+		*out = make([]rbacv1.Subject, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NamespacelabelConfigTenantRBAC.
+func (in *NamespacelabelConfigTenantRBAC) DeepCopy() *NamespacelabelConfigTenantRBAC {
+	if in == nil {
+		return nil
+	}
+	out := new(NamespacelabelConfigTenantRBAC)
+	in.DeepCopyInto(out)
+	return out
+}
@@ -0,0 +1,134 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"path"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// ClusterNamespaceLabelSpec defines the desired state of ClusterNamespaceLabel
+type ClusterNamespaceLabelSpec struct {
+	// Map of string keys and values that are used to add labels to the selected namespaces
+	Labels map[string]string `json:"labels,omitempty"`
+
+	// Map of string keys and values that are used to add annotations to the selected namespaces
+	Annotations map[string]string `json:"annotations,omitempty"`
+
+	// Selects the namespaces by their labels. It must be set, an empty selector selects every
+	// namespace while a missing one selects none
+	NamespaceSelector *metav1.LabelSelector `json:"namespaceSelector"`
+
+	// List of glob patterns of namespace names, when set a namespace must
+	// match one of the patterns in addition to the namespace selector
+	// +optional
+	NamespaceNamePatterns []string `json:"namespaceNamePatterns,omitempty"`
+
+	// Priority of this ClusterNamespaceLabel when several ClusterNamespaceLabels select the same
+	// namespace and request the same key. The highest priority wins, ties are won by the oldest
+	// +optional
+	Priority int32 `json:"priority,omitempty"`
+}
+
+// ClusterNamespaceLabelTarget holds the keys a ClusterNamespaceLabel currently manages on a namespace
+type ClusterNamespaceLabelTarget struct {
+	// Name of the namespace
+	Name string `json:"name"`
+
+	// Map of currently active labels on the namespace
+	ActiveLabels map[string]string `json:"activeLabels,omitempty"`
+
+	// Map of currently active annotations on the namespace
+	ActiveAnnotations map[string]string `json:"activeAnnotations,omitempty"`
+}
+
+// ClusterNamespaceLabelStatus defines the observed state of ClusterNamespaceLabel
+type ClusterNamespaceLabelStatus struct {
+	// Names of the namespaces currently selected by the ClusterNamespaceLabel
+	MatchedNamespaces []string `json:"matchedNamespaces,omitempty"`
+
+	// Keys currently managed on each selected namespace
+	Targets []ClusterNamespaceLabelTarget `json:"targets,omitempty"`
+
+	// Latest available observations of the ClusterNamespaceLabel state
+	//+listType=map
+	//+listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+
+	// Generation of the ClusterNamespaceLabel that was last reconciled
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:resource:scope=Cluster
+//+kubebuilder:printcolumn:name="Priority",type="integer",JSONPath=".spec.priority"
+//+kubebuilder:printcolumn:name="Ready",type="string",JSONPath=".status.conditions[?(@.type==\"Ready\")].status"
+//+kubebuilder:printcolumn:name="Namespaces",type="string",JSONPath=".status.matchedNamespaces",priority=1
+//+kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+
+// ClusterNamespaceLabel is the Schema for the clusternamespacelabels API
+type ClusterNamespaceLabel struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ClusterNamespaceLabelSpec   `json:"spec,omitempty"`
+	Status ClusterNamespaceLabelStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// ClusterNamespaceLabelList contains a list of ClusterNamespaceLabel
+type ClusterNamespaceLabelList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ClusterNamespaceLabel `json:"items"`
+}
+
+// MatchesNamespace reports whether the ClusterNamespaceLabel selects a namespace
+// with the given name and labels. Without a namespace selector no namespace is selected
+func (r *ClusterNamespaceLabel) MatchesNamespace(name string, nsLabels map[string]string) (bool, error) {
+	if r.Spec.NamespaceSelector == nil {
+		return false, nil
+	}
+	selector, err := metav1.LabelSelectorAsSelector(r.Spec.NamespaceSelector)
+	if err != nil {
+		return false, err
+	}
+
+	if !selector.Matches(labels.Set(nsLabels)) {
+		return false, nil
+	}
+
+	if len(r.Spec.NamespaceNamePatterns) == 0 {
+		return true, nil
+	}
+
+	for _, pattern := range r.Spec.NamespaceNamePatterns {
+		if matched, err := path.Match(pattern, name); err == nil && matched {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func init() {
+	SchemeBuilder.Register(&ClusterNamespaceLabel{}, &ClusterNamespaceLabelList{})
+}
@@ -0,0 +1,101 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"context"
+	"path"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+
+	configv1alpha1 "home-assignment/apis/config/v1alpha1"
+)
+
+// log is for logging in this package.
+var clusternamespacelabellog = logf.Log.WithName("clusternamespacelabel-resource")
+
+func (r *ClusterNamespaceLabel) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	namespacelabelClient = mgr.GetClient()
+
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(r).
+		Complete()
+}
+
+//+kubebuilder:webhook:path=/validate-dana-io-dana-io-v1alpha1-clusternamespacelabel,mutating=false,failurePolicy=fail,sideEffects=None,groups=dana.io.dana.io,resources=clusternamespacelabels,verbs=create;update,versions=v1alpha1,name=vclusternamespacelabel.kb.io,admissionReviewVersions=v1
+
+var _ webhook.Validator = &ClusterNamespaceLabel{}
+
+// ValidateCreate implements webhook.Validator so a webhook will be registered for the type
+func (r *ClusterNamespaceLabel) ValidateCreate() error {
+	clusternamespacelabellog.Info("validate create", "name", r.Name)
+
+	return r.validateClusterNamespaceLabel()
+}
+
+// ValidateUpdate implements webhook.Validator so a webhook will be registered for the type
+func (r *ClusterNamespaceLabel) ValidateUpdate(old runtime.Object) error {
+	clusternamespacelabellog.Info("validate update", "name", r.Name)
+
+	return r.validateClusterNamespaceLabel()
+}
+
+// ValidateDelete implements webhook.Validator so a webhook will be registered for the type
+func (r *ClusterNamespaceLabel) ValidateDelete() error {
+	return nil
+}
+
+// validateClusterNamespaceLabel checks the keys against the same cluster policy as the keys of
+// a NamespaceLabel, and requires a namespace selector so no namespace is selected by accident
+func (r *ClusterNamespaceLabel) validateClusterNamespaceLabel() error {
+	allErrs := field.ErrorList{}
+	allErrs = append(allErrs, ValidateLabelSyntax(field.NewPath("spec", "labels"), r.Spec.Labels)...)
+	allErrs = append(allErrs, ValidateAnnotationSyntax(field.NewPath("spec", "annotations"), r.Spec.Annotations)...)
+
+	if r.Spec.NamespaceSelector == nil {
+		allErrs = append(allErrs, field.Required(field.NewPath("spec", "namespaceSelector"), "an empty selector {} selects every namespace"))
+	} else if _, err := metav1.LabelSelectorAsSelector(r.Spec.NamespaceSelector); err != nil {
+		allErrs = append(allErrs, field.Invalid(field.NewPath("spec", "namespaceSelector"), r.Spec.NamespaceSelector, err.Error()))
+	}
+	for i, pattern := range r.Spec.NamespaceNamePatterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			allErrs = append(allErrs, field.Invalid(field.NewPath("spec", "namespaceNamePatterns").Index(i), pattern, err.Error()))
+		}
+	}
+
+	if namespacelabelClient != nil {
+		config, err := configv1alpha1.GetClusterConfig(context.Background(), namespacelabelClient)
+		if err != nil {
+			clusternamespacelabellog.Error(err, "unable to fetch namespacelabelconfig")
+			return err
+		}
+		allErrs = append(allErrs, config.Spec.ValidateLabels(field.NewPath("spec", "labels"), r.Spec.Labels)...)
+		allErrs = append(allErrs, config.Spec.ValidateAnnotations(field.NewPath("spec", "annotations"), r.Spec.Annotations)...)
+	}
+
+	if len(allErrs) == 0 {
+		return nil
+	}
+
+	return apierrors.NewInvalid(GroupVersion.WithKind("ClusterNamespaceLabel").GroupKind(), r.Name, allErrs)
+}
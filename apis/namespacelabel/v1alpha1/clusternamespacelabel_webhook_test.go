@@ -0,0 +1,83 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"testing"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	configv1alpha1 "home-assignment/apis/config/v1alpha1"
+)
+
+func TestValidateClusterNamespaceLabel(t *testing.T) {
+	s := runtime.NewScheme()
+	if err := configv1alpha1.AddToScheme(s); err != nil {
+		t.Fatalf("Unable to add to scheme: %v", err)
+	}
+	config := &configv1alpha1.NamespacelabelConfig{
+		ObjectMeta: metav1.ObjectMeta{Name: configv1alpha1.ClusterConfigName},
+		Spec:       configv1alpha1.NamespacelabelConfigSpec{ProtectedDomains: []string{"kubernetes.io"}},
+	}
+	namespacelabelClient = fake.NewClientBuilder().WithScheme(s).WithObjects(config).Build()
+	defer func() { namespacelabelClient = nil }()
+
+	clusterNamespaceLabel := &ClusterNamespaceLabel{
+		ObjectMeta: metav1.ObjectMeta{Name: "zones"},
+		Spec: ClusterNamespaceLabelSpec{
+			Labels:            map[string]string{"network-zone": "internal"},
+			NamespaceSelector: &metav1.LabelSelector{},
+		},
+	}
+	if err := clusterNamespaceLabel.ValidateCreate(); err != nil {
+		t.Errorf("expected a valid ClusterNamespaceLabel to be allowed: %v", err)
+	}
+
+	// the keys are subject to the cluster policy, and the parent annotation can not be set
+	invalid := clusterNamespaceLabel.DeepCopy()
+	invalid.Spec.NamespaceSelector = nil
+	invalid.Spec.Labels["kubernetes.io/zone"] = "internal"
+	invalid.Spec.Annotations = map[string]string{configv1alpha1.DefaultParentAnnotation: "team"}
+	err := invalid.ValidateUpdate(clusterNamespaceLabel)
+	if !apierrors.IsInvalid(err) {
+		t.Fatalf("expected an invalid error, got %v", err)
+	}
+	fields := map[string]bool{}
+	for _, cause := range err.(apierrors.APIStatus).Status().Details.Causes {
+		fields[cause.Field] = true
+	}
+	for _, fld := range []string{"spec.namespaceSelector", "spec.labels[kubernetes.io/zone]", "spec.annotations[dana.io/parent]"} {
+		if !fields[fld] {
+			t.Errorf("expected %s to be denied, got %v", fld, err)
+		}
+	}
+}
+
+func TestMatchesNamespaceWithoutSelector(t *testing.T) {
+	clusterNamespaceLabel := &ClusterNamespaceLabel{ObjectMeta: metav1.ObjectMeta{Name: "zones"}}
+	if matches, err := clusterNamespaceLabel.MatchesNamespace("team-a", nil); err != nil || matches {
+		t.Errorf("expected a missing selector to select no namespace, got %v, %v", matches, err)
+	}
+
+	clusterNamespaceLabel.Spec.NamespaceSelector = &metav1.LabelSelector{}
+	if matches, err := clusterNamespaceLabel.MatchesNamespace("team-a", nil); err != nil || !matches {
+		t.Errorf("expected an empty selector to select every namespace, got %v, %v", matches, err)
+	}
+}
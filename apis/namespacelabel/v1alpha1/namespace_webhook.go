@@ -0,0 +1,171 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	configv1alpha1 "home-assignment/apis/config/v1alpha1"
+)
+
+// log is for logging the namespace webhook.
+var namespacelog = logf.Log.WithName("namespace-resource")
+
+// NamespaceValidator rejects direct changes to namespace keys that are owned by a NamespaceLabel,
+// so the controller does not end up fighting other writers over them
+// +kubebuilder:object:generate=false
+type NamespaceValidator struct {
+	Client client.Reader
+
+	// Username of the manager, whose own requests are always allowed
+	ManagerUsername string
+
+	decoder *admission.Decoder
+}
+
+// SetupNamespaceWebhookWithManager registers the namespace webhook with the webhook server of the manager
+func SetupNamespaceWebhookWithManager(mgr ctrl.Manager, managerUsername string) error {
+	mgr.GetWebhookServer().Register("/validate-v1-namespace", &webhook.Admission{
+		Handler: &NamespaceValidator{
+			Client:          mgr.GetClient(),
+			ManagerUsername: managerUsername,
+		},
+	})
+
+	return nil
+}
+
+// the failure policy is ignore, since an unavailable manager must not block every namespace update.
+// Keys changed while the webhook is down are restored by the controller
+//+kubebuilder:webhook:path=/validate-v1-namespace,mutating=false,failurePolicy=ignore,sideEffects=None,groups="",resources=namespaces,verbs=update,versions=v1,name=vnamespace.kb.io,admissionReviewVersions=v1
+//+kubebuilder:rbac:groups=dana.io.dana.io,resources=namespacelabels,verbs=get;list;watch
+//+kubebuilder:rbac:groups=dana.io.dana.io,resources=clusternamespacelabels,verbs=get;list;watch
+
+var _ admission.Handler = &NamespaceValidator{}
+var _ admission.DecoderInjector = &NamespaceValidator{}
+
+// Handle rejects namespace updates that change or remove keys owned by a NamespaceLabel,
+// unless they come from the manager or from a member of an allowed group
+func (v *NamespaceValidator) Handle(ctx context.Context, req admission.Request) admission.Response {
+	namespacelog.Info("validate update", "name", req.Name, "user", req.UserInfo.Username)
+
+	if v.ManagerUsername != "" && req.UserInfo.Username == v.ManagerUsername {
+		return admission.Allowed("")
+	}
+
+	config, err := configv1alpha1.GetClusterConfig(ctx, v.Client)
+	if err != nil {
+		namespacelog.Error(err, "unable to fetch namespacelabelconfig")
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+	for _, group := range req.UserInfo.Groups {
+		for _, allowed := range config.Spec.NamespaceEditorGroups {
+			if group == allowed {
+				return admission.Allowed("")
+			}
+		}
+	}
+
+	namespace := &v1.Namespace{}
+	if err := v.decoder.DecodeRaw(req.Object, namespace); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+	oldNamespace := &v1.Namespace{}
+	if err := v.decoder.DecodeRaw(req.OldObject, oldNamespace); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+
+	namespaceLabels := &NamespaceLabelList{}
+	if err := v.Client.List(ctx, namespaceLabels, client.InNamespace(namespace.Name)); err != nil {
+		namespacelog.Error(err, "unable to list namespaceLabels")
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+	clusterNamespaceLabels := &ClusterNamespaceLabelList{}
+	if err := v.Client.List(ctx, clusterNamespaceLabels); err != nil {
+		namespacelog.Error(err, "unable to list clusterNamespaceLabels")
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+
+	violations := GetOwnedKeyChanges(oldNamespace, namespace, namespaceLabels.Items, clusterNamespaceLabels.Items)
+	if len(violations) > 0 {
+		return admission.Denied(strings.Join(violations, "; "))
+	}
+
+	return admission.Allowed("")
+}
+
+// InjectDecoder implements admission.DecoderInjector so the webhook server provides the decoder
+func (v *NamespaceValidator) InjectDecoder(d *admission.Decoder) error {
+	v.decoder = d
+	return nil
+}
+
+// GetOwnedKeyChanges returns a message for every key owned by a NamespaceLabel or a
+// ClusterNamespaceLabel that is changed or removed between the old and the new namespace
+func GetOwnedKeyChanges(oldNamespace *v1.Namespace, namespace *v1.Namespace, namespaceLabels []NamespaceLabel, clusterNamespaceLabels []ClusterNamespaceLabel) []string {
+	violations := []string{}
+
+	for _, namespaceLabel := range namespaceLabels {
+		owner := fmt.Sprintf("NamespaceLabel %s/%s", namespaceLabel.Namespace, namespaceLabel.Name)
+		violations = append(violations, getChangedKeys("label", owner, namespaceLabel.Status.ActiveLabels, oldNamespace.Labels, namespace.Labels)...)
+		violations = append(violations, getChangedKeys("annotation", owner, namespaceLabel.Status.ActiveAnnotations, oldNamespace.Annotations, namespace.Annotations)...)
+	}
+
+	for _, clusterNamespaceLabel := range clusterNamespaceLabels {
+		owner := fmt.Sprintf("ClusterNamespaceLabel %s", clusterNamespaceLabel.Name)
+		for _, target := range clusterNamespaceLabel.Status.Targets {
+			if target.Name != namespace.Name {
+				continue
+			}
+			violations = append(violations, getChangedKeys("label", owner, target.ActiveLabels, oldNamespace.Labels, namespace.Labels)...)
+			violations = append(violations, getChangedKeys("annotation", owner, target.ActiveAnnotations, oldNamespace.Annotations, namespace.Annotations)...)
+		}
+	}
+
+	sort.Strings(violations)
+	return violations
+}
+
+// getChangedKeys returns a message for every owned key whose value differs between the old
+// and the new keys of the namespace, keys that were already out of sync may be fixed freely
+func getChangedKeys(keyType string, owner string, ownedKeys map[string]string, oldKeys map[string]string, newKeys map[string]string) []string {
+	changed := []string{}
+
+	for key, val := range ownedKeys {
+		oldVal, oldOk := oldKeys[key]
+		newVal, newOk := newKeys[key]
+		if oldOk != newOk || oldVal != newVal {
+			if newOk && newVal == val {
+				continue
+			}
+			changed = append(changed, fmt.Sprintf("%s %s is owned by %s", keyType, key, owner))
+		}
+	}
+
+	return changed
+}
@@ -0,0 +1,78 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"reflect"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestGetOwnedKeyChanges(t *testing.T) {
+	oldNamespace := &v1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "team-a",
+			Labels: map[string]string{
+				"team":         "a",
+				"network-zone": "internal",
+				"free":         "x",
+			},
+			Annotations: map[string]string{
+				"owner": "alice",
+			},
+		},
+	}
+	namespace := oldNamespace.DeepCopy()
+	namespace.Labels["team"] = "b"
+	namespace.Labels["free"] = "y"
+	delete(namespace.Labels, "network-zone")
+	delete(namespace.Annotations, "owner")
+
+	namespaceLabels := []NamespaceLabel{{
+		ObjectMeta: metav1.ObjectMeta{Name: "tenant", Namespace: "team-a"},
+		Status: NamespaceLabelStatus{
+			ActiveLabels:      map[string]string{"team": "a"},
+			ActiveAnnotations: map[string]string{"owner": "alice"},
+		},
+	}}
+	clusterNamespaceLabels := []ClusterNamespaceLabel{{
+		ObjectMeta: metav1.ObjectMeta{Name: "zones"},
+		Status: ClusterNamespaceLabelStatus{
+			Targets: []ClusterNamespaceLabelTarget{
+				{Name: "team-a", ActiveLabels: map[string]string{"network-zone": "internal"}},
+				{Name: "team-b", ActiveLabels: map[string]string{"free": "x"}},
+			},
+		},
+	}}
+
+	expected := []string{
+		"annotation owner is owned by NamespaceLabel team-a/tenant",
+		"label network-zone is owned by ClusterNamespaceLabel zones",
+		"label team is owned by NamespaceLabel team-a/tenant",
+	}
+
+	if violations := GetOwnedKeyChanges(oldNamespace, namespace, namespaceLabels, clusterNamespaceLabels); !reflect.DeepEqual(violations, expected) {
+		t.Errorf("GetOwnedKeyChanges returned %v, expected %v", violations, expected)
+	}
+
+	// restoring an owned key to its active value is always allowed
+	if violations := GetOwnedKeyChanges(namespace, oldNamespace, namespaceLabels, clusterNamespaceLabels); len(violations) != 0 {
+		t.Errorf("expected restoring owned keys to be allowed, got %v", violations)
+	}
+}
@@ -0,0 +1,186 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"regexp"
+	"strings"
+	"text/template"
+	"text/template/parse"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+// templateFuncs are the functions available to templated values
+var templateFuncs = template.FuncMap{
+	"regexCapture": regexCapture,
+}
+
+// IsTemplate reports whether a label or annotation value is a template
+func IsTemplate(value string) bool {
+	return strings.Contains(value, "{{")
+}
+
+// ParseTemplate parses a templated value, such as {{ .Namespace.Annotations.owner }}
+func ParseTemplate(value string) (*template.Template, error) {
+	return template.New("value").Funcs(templateFuncs).Option("missingkey=zero").Parse(value)
+}
+
+// RenderValue renders a templated value from the metadata of the namespace, values that
+// are not templates are returned as is. Missing labels and annotations render empty
+func RenderValue(value string, namespace *v1.Namespace) (string, error) {
+	if !IsTemplate(value) {
+		return value, nil
+	}
+
+	tmpl, err := ParseTemplate(value)
+	if err != nil {
+		return "", err
+	}
+
+	data := map[string]interface{}{
+		"Namespace": map[string]interface{}{
+			"Name":        namespace.Name,
+			"Labels":      nonNilMap(namespace.Labels),
+			"Annotations": nonNilMap(namespace.Annotations),
+		},
+	}
+	rendered := &strings.Builder{}
+	if err := tmpl.Execute(rendered, data); err != nil {
+		return "", err
+	}
+
+	return rendered.String(), nil
+}
+
+// ReferencedKeys returns the keys of the namespace Labels or Annotations, depending on the field,
+// that a templated value reads. whole is set when the template reads the map itself, such as in
+// a range, so any of its keys may be read
+func ReferencedKeys(value string, metadataField string) ([]string, bool, error) {
+	tmpl, err := ParseTemplate(value)
+	if err != nil {
+		return nil, false, err
+	}
+
+	refs := []string{}
+	whole := false
+	// ident is the path of a field, such as Namespace Labels team
+	visitIdent := func(ident []string) {
+		if len(ident) > 0 && ident[0] == "$" {
+			ident = ident[1:]
+		}
+		// the namespace itself may be handed on, such as in a with
+		if len(ident) == 1 && ident[0] == "Namespace" {
+			whole = true
+			return
+		}
+		if len(ident) < 2 || ident[0] != "Namespace" || ident[1] != metadataField {
+			return
+		}
+		if len(ident) == 2 {
+			whole = true
+			return
+		}
+		refs = append(refs, ident[2])
+	}
+
+	var visit func(node parse.Node)
+	visit = func(node parse.Node) {
+		switch n := node.(type) {
+		case *parse.ListNode:
+			if n == nil {
+				return
+			}
+			for _, child := range n.Nodes {
+				visit(child)
+			}
+		case *parse.ActionNode:
+			visit(n.Pipe)
+		case *parse.PipeNode:
+			if n == nil {
+				return
+			}
+			for _, cmd := range n.Cmds {
+				visit(cmd)
+			}
+		case *parse.CommandNode:
+			// index .Namespace.Labels "key" reads a single key
+			if len(n.Args) == 3 {
+				fn, isIdent := n.Args[0].(*parse.IdentifierNode)
+				fld, isField := n.Args[1].(*parse.FieldNode)
+				key, isString := n.Args[2].(*parse.StringNode)
+				if isIdent && fn.Ident == "index" && isField && isString &&
+					len(fld.Ident) == 2 && fld.Ident[0] == "Namespace" && fld.Ident[1] == metadataField {
+					refs = append(refs, key.Text)
+					return
+				}
+			}
+			for _, arg := range n.Args {
+				visit(arg)
+			}
+		case *parse.FieldNode:
+			visitIdent(n.Ident)
+		case *parse.VariableNode:
+			visitIdent(n.Ident)
+		case *parse.ChainNode:
+			visit(n.Node)
+		case *parse.IfNode:
+			visitBranch(&n.BranchNode, visit)
+		case *parse.RangeNode:
+			visitBranch(&n.BranchNode, visit)
+		case *parse.WithNode:
+			visitBranch(&n.BranchNode, visit)
+		case *parse.TemplateNode:
+			visit(n.Pipe)
+		}
+	}
+	visit(tmpl.Tree.Root)
+
+	return refs, whole, nil
+}
+
+// visitBranch visits the pipeline and both lists of an if, range or with node
+func visitBranch(n *parse.BranchNode, visit func(parse.Node)) {
+	visit(n.Pipe)
+	visit(n.List)
+	visit(n.ElseList)
+}
+
+// regexCapture returns the first capture group of the pattern in s, or an empty string
+// when the pattern does not match
+func regexCapture(s string, pattern string) (string, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return "", err
+	}
+
+	match := re.FindStringSubmatch(s)
+	if len(match) < 2 {
+		return "", nil
+	}
+
+	return match[1], nil
+}
+
+// nonNilMap returns an empty map in place of nil, so that missing keys render empty
+func nonNilMap(m map[string]string) map[string]string {
+	if m == nil {
+		return map[string]string{}
+	}
+
+	return m
+}
@@ -0,0 +1,88 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"reflect"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestRenderValue(t *testing.T) {
+	namespace := &v1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "prod-payments",
+			Labels:      map[string]string{"team": "payments"},
+			Annotations: map[string]string{"owner": "alice"},
+		},
+	}
+
+	tests := map[string]string{
+		"static":                                            "static",
+		"{{ .Namespace.Annotations.owner }}":                "alice",
+		"{{ .Namespace.Labels.team }}-api":                  "payments-api",
+		"{{ .Namespace.Annotations.missing }}":              "",
+		`{{ regexCapture .Namespace.Name "^(dev|prod)-" }}`: "prod",
+		`{{ regexCapture .Namespace.Name "^(test|qa)-" }}`:  "",
+		`{{ .Namespace.Name | printf "%.4s" }}`:             "prod",
+	}
+
+	for value, expected := range tests {
+		rendered, err := RenderValue(value, namespace)
+		if err != nil {
+			t.Errorf("RenderValue(%q) returned %v", value, err)
+			continue
+		}
+		if rendered != expected {
+			t.Errorf("RenderValue(%q) = %q, expected %q", value, rendered, expected)
+		}
+	}
+
+	for _, value := range []string{"{{ .Namespace.Name", `{{ regexCapture .Namespace.Name "(" }}`, "{{ unknown }}"} {
+		if _, err := RenderValue(value, namespace); err == nil {
+			t.Errorf("expected RenderValue(%q) to fail", value)
+		}
+	}
+}
+
+func TestReferencedKeys(t *testing.T) {
+	tests := []struct {
+		value string
+		refs  []string
+		whole bool
+	}{
+		{"{{ .Namespace.Name }}", []string{}, false},
+		{"{{ .Namespace.Labels.team }}-{{ .Namespace.Annotations.owner }}", []string{"team"}, false},
+		{`{{ index .Namespace.Labels "app.kubernetes.io/name" }}`, []string{"app.kubernetes.io/name"}, false},
+		{`{{ if .Namespace.Labels.env }}{{ regexCapture $.Namespace.Labels.tier "(.*)" }}{{ end }}`, []string{"env", "tier"}, false},
+		{"{{ range $k, $v := .Namespace.Labels }}{{ $v }}{{ end }}", []string{}, true},
+		{"{{ with .Namespace }}{{ .Labels.team }}{{ end }}", []string{}, true},
+	}
+
+	for _, test := range tests {
+		refs, whole, err := ReferencedKeys(test.value, "Labels")
+		if err != nil {
+			t.Errorf("ReferencedKeys(%q) returned %v", test.value, err)
+			continue
+		}
+		if !reflect.DeepEqual(refs, test.refs) || whole != test.whole {
+			t.Errorf("ReferencedKeys(%q) = %v, %v, expected %v, %v", test.value, refs, whole, test.refs, test.whole)
+		}
+	}
+}
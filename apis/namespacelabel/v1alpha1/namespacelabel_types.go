@@ -25,18 +25,291 @@ import (
 
 // NamespaceLabelSpec defines the desired state of NamespaceLabel
 type NamespaceLabelSpec struct {
-	// Map of string keys and values that are used to add labels to namespace
+	// Map of string keys and values that are used to add labels to namespace. Values can be
+	// templates rendered from the namespace, such as {{ .Namespace.Annotations.owner }} or
+	// {{ regexCapture .Namespace.Name "^(dev|prod)-" }}
 	Labels map[string]string `json:"labels,omitempty"`
+
+	// Map of string keys and values that are used to add annotations to namespace, values can be
+	// templates like the values of labels
+	Annotations map[string]string `json:"annotations,omitempty"`
+
+	// Priority of this NamespaceLabel when several NamespaceLabels in the namespace request
+	// the same key. The highest priority wins, ties are won by the oldest NamespaceLabel
+	// +optional
+	Priority int32 `json:"priority,omitempty"`
+
+	// ConflictPolicy decides what happens to requested keys that are already set on the
+	// namespace by someone else than a NamespaceLabel. Defaults to Skip
+	// +kubebuilder:default=Skip
+	// +optional
+	ConflictPolicy ConflictPolicy `json:"conflictPolicy,omitempty"`
+
+	// List of label or annotation keys whose value can not be changed or removed once set,
+	// unless by an admin
+	// +optional
+	ImmutableKeys []string `json:"immutableKeys,omitempty"`
+
+	// Locked blocks every change and the deletion of the NamespaceLabel, unless by an admin
+	// +optional
+	Locked bool `json:"locked,omitempty"`
+
+	// Propagation passes labels of this NamespaceLabel on to the descendant namespaces
+	// +optional
+	Propagation *Propagation `json:"propagation,omitempty"`
+
+	// List of schedules limiting the time window in which keys are set on the namespace
+	// +optional
+	Schedules []KeySchedule `json:"schedules,omitempty"`
+
+	// Suspend stops syncing the NamespaceLabel to the namespace, the keys already applied stay
+	// in place. Deleting a suspended NamespaceLabel still releases its keys
+	// +optional
+	Suspend bool `json:"suspend,omitempty"`
+
+	// DryRun records the changes a sync would make to the namespace in the status as planned
+	// changes, without touching the namespace
+	// +optional
+	DryRun bool `json:"dryRun,omitempty"`
+
+	// DeletionPolicy decides what happens to the keys on the namespace when the NamespaceLabel
+	// is deleted. Defaults to RestoreOriginal
+	// +kubebuilder:default=RestoreOriginal
+	// +optional
+	DeletionPolicy DeletionPolicy `json:"deletionPolicy,omitempty"`
+
+	// List of deletion policies of single keys, overriding the deletion policy of the NamespaceLabel
+	// +optional
+	KeyDeletionPolicies []KeyDeletionPolicy `json:"keyDeletionPolicies,omitempty"`
+
+	// RollbackTo restores the labels and annotations of a recorded revision. The webhook replaces
+	// the keys and clears the field in the same request, so the rollback is validated like any change
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	RollbackTo *int64 `json:"rollbackTo,omitempty"`
+}
+
+// KeyDeletionPolicy sets the deletion policy of a single label or annotation key
+type KeyDeletionPolicy struct {
+	// Label or annotation key of the NamespaceLabel the policy applies to
+	Key string `json:"key"`
+
+	// Policy applied to the key when the NamespaceLabel is deleted
+	Policy DeletionPolicy `json:"policy"`
+}
+
+// PlannedChanges are the changes a sync of a NamespaceLabel in dry run would make to the namespace
+type PlannedChanges struct {
+	// Map of labels that would be added to the namespace or changed on it
+	AddLabels map[string]string `json:"addLabels,omitempty"`
+
+	// Map of labels that would be removed from the namespace, with their active value
+	DeleteLabels map[string]string `json:"deleteLabels,omitempty"`
+
+	// Map of annotations that would be added to the namespace or changed on it
+	AddAnnotations map[string]string `json:"addAnnotations,omitempty"`
+
+	// Map of annotations that would be removed from the namespace, with their active value
+	DeleteAnnotations map[string]string `json:"deleteAnnotations,omitempty"`
+}
+
+// KeySchedule limits the time window in which a label or annotation key is set on the namespace
+type KeySchedule struct {
+	// Label or annotation key of the NamespaceLabel the schedule applies to
+	Key string `json:"key"`
+
+	// Time the key is added to the namespace, when unset the key is added right away
+	// +optional
+	NotBefore *metav1.Time `json:"notBefore,omitempty"`
+
+	// Time the key is removed from the namespace, when unset the key does not expire
+	// +optional
+	ExpiresAt *metav1.Time `json:"expiresAt,omitempty"`
+}
+
+// Propagation selects the labels a NamespaceLabel propagates to the namespaces below its namespace
+type Propagation struct {
+	// List of label keys inherited by the namespaces whose parent annotation names this
+	// namespace, and by their descendants in turn. The nearest ancestor wins
+	Keys []string `json:"keys"`
+
+	// Allow NamespaceLabels in descendant namespaces to override the inherited values
+	// +optional
+	AllowOverrides bool `json:"allowOverrides,omitempty"`
+}
+
+// ConflictPolicy describes how a NamespaceLabel handles keys that are already set on the namespace
+// +kubebuilder:validation:Enum=Skip;Takeover;Fail
+type ConflictPolicy string
+
+const (
+	// ConflictPolicySkip leaves the pre-existing value in place and reports the key as a conflict
+	ConflictPolicySkip ConflictPolicy = "Skip"
+	// ConflictPolicyTakeover overwrites the pre-existing value and restores it when the key is released
+	ConflictPolicyTakeover ConflictPolicy = "Takeover"
+	// ConflictPolicyFail stops syncing the NamespaceLabel until the pre-existing value is removed
+	ConflictPolicyFail ConflictPolicy = "Fail"
+)
+
+// DeletionPolicy describes what happens to a key on the namespace when its NamespaceLabel is deleted
+// +kubebuilder:validation:Enum=Delete;Retain;RestoreOriginal
+type DeletionPolicy string
+
+const (
+	// DeletionPolicyDelete removes the key from the namespace
+	DeletionPolicyDelete DeletionPolicy = "Delete"
+	// DeletionPolicyRetain leaves the key on the namespace unmanaged, so another source can claim it
+	DeletionPolicyRetain DeletionPolicy = "Retain"
+	// DeletionPolicyRestoreOriginal puts back the value the key had before it was taken over,
+	// and removes the key when it was not set before
+	DeletionPolicyRestoreOriginal DeletionPolicy = "RestoreOriginal"
+)
+
+// GetDeletionPolicy returns the deletion policy of a key, a policy set for the key itself
+// takes precedence over the policy of the NamespaceLabel
+func (r *NamespaceLabel) GetDeletionPolicy(key string) DeletionPolicy {
+	for _, keyPolicy := range r.Spec.KeyDeletionPolicies {
+		if keyPolicy.Key == key {
+			return keyPolicy.Policy
+		}
+	}
+	if r.Spec.DeletionPolicy == "" {
+		return DeletionPolicyRestoreOriginal
+	}
+
+	return r.Spec.DeletionPolicy
+}
+
+// Condition types of a NamespaceLabel
+const (
+	// ConditionReady indicates that all requested keys are active on the namespace
+	ConditionReady = "Ready"
+	// ConditionSynced indicates whether the last sync of the namespace succeeded
+	ConditionSynced = "Synced"
+	// ConditionConflict indicates that requested keys are owned by another source
+	ConditionConflict = "Conflict"
+	// ConditionDegraded indicates that some requested keys could not be applied
+	ConditionDegraded = "Degraded"
+	// ConditionQuotaExceeded indicates that the keys exceed the limits of the cluster policy,
+	// which happens when a limit is lowered below the keys of existing objects
+	ConditionQuotaExceeded = "QuotaExceeded"
+	// ConditionSuspended indicates that syncing the NamespaceLabel is suspended
+	ConditionSuspended = "Suspended"
+)
+
+// KeyType is the kind of namespace metadata a key belongs to
+// +kubebuilder:validation:Enum=Label;Annotation
+type KeyType string
+
+const (
+	KeyTypeLabel      KeyType = "Label"
+	KeyTypeAnnotation KeyType = "Annotation"
+)
+
+// KeyResultStatus is the outcome of syncing a single requested key
+// +kubebuilder:validation:Enum=Applied;Pending;Rejected;Conflict;Scheduled;Expired;TemplateFailed
+type KeyResultStatus string
+
+const (
+	KeyResultApplied        KeyResultStatus = "Applied"
+	KeyResultPending        KeyResultStatus = "Pending"
+	KeyResultRejected       KeyResultStatus = "Rejected"
+	KeyResultConflict       KeyResultStatus = "Conflict"
+	KeyResultScheduled      KeyResultStatus = "Scheduled"
+	KeyResultExpired        KeyResultStatus = "Expired"
+	KeyResultTemplateFailed KeyResultStatus = "TemplateFailed"
+)
+
+// KeyTransitionAction is what happens to a key at a scheduled transition
+// +kubebuilder:validation:Enum=Add;Remove
+type KeyTransitionAction string
+
+const (
+	KeyTransitionAdd    KeyTransitionAction = "Add"
+	KeyTransitionRemove KeyTransitionAction = "Remove"
+)
+
+// KeyTransition is an upcoming change of a scheduled key on the namespace
+type KeyTransition struct {
+	// Key that is added or removed
+	Key string `json:"key"`
+
+	// Action taken on the key, either Add or Remove
+	Action KeyTransitionAction `json:"action"`
+
+	// Time of the transition
+	Time metav1.Time `json:"time"`
+}
+
+// KeyResult describes the outcome of syncing a single requested key to the namespace
+type KeyResult struct {
+	// Key of the label or annotation
+	Key string `json:"key"`
+
+	// Type of the key, either Label or Annotation
+	Type KeyType `json:"type"`
+
+	// Result of syncing the key
+	Result KeyResultStatus `json:"result"`
+
+	// Human readable message explaining the result
+	Message string `json:"message,omitempty"`
 }
 
 // NamespaceLabelStatus defines the observed state of NamespaceLabel
 type NamespaceLabelStatus struct {
 	// Map of currently active user-added labels on namespace
 	ActiveLabels map[string]string `json:"activeLabels,omitempty"`
+
+	// Map of currently active user-added annotations on namespace
+	ActiveAnnotations map[string]string `json:"activeAnnotations,omitempty"`
+
+	// Latest available observations of the NamespaceLabel state
+	//+listType=map
+	//+listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+
+	// Generation of the NamespaceLabel that was last reconciled
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// Last time the requested keys were synced to the namespace
+	LastSyncTime *metav1.Time `json:"lastSyncTime,omitempty"`
+
+	// Result of syncing each requested key
+	KeyResults []KeyResult `json:"keyResults,omitempty"`
+
+	// Map of labels that were set on the namespace before they were taken over,
+	// restored when the NamespaceLabel releases them
+	OriginalLabels map[string]string `json:"originalLabels,omitempty"`
+
+	// Map of annotations that were set on the namespace before they were taken over,
+	// restored when the NamespaceLabel releases them
+	OriginalAnnotations map[string]string `json:"originalAnnotations,omitempty"`
+
+	// List of upcoming additions and removals of scheduled keys, ordered by time
+	UpcomingTransitions []KeyTransition `json:"upcomingTransitions,omitempty"`
+
+	// Changes the last sync would have made to the namespace, set only in dry run
+	PlannedChanges *PlannedChanges `json:"plannedChanges,omitempty"`
+
+	// Number of the revision recording the labels and annotations last applied
+	CurrentRevision int64 `json:"currentRevision,omitempty"`
 }
 
 //+kubebuilder:object:root=true
 //+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Priority",type="integer",JSONPath=".spec.priority"
+//+kubebuilder:printcolumn:name="Locked",type="boolean",JSONPath=".spec.locked",priority=1
+//+kubebuilder:printcolumn:name="Conflict Policy",type="string",JSONPath=".spec.conflictPolicy",priority=1
+//+kubebuilder:printcolumn:name="Suspend",type="boolean",JSONPath=".spec.suspend",priority=1
+//+kubebuilder:printcolumn:name="Dry Run",type="boolean",JSONPath=".spec.dryRun",priority=1
+//+kubebuilder:printcolumn:name="Ready",type="string",JSONPath=".status.conditions[?(@.type==\"Ready\")].status"
+//+kubebuilder:printcolumn:name="Synced",type="string",JSONPath=".status.conditions[?(@.type==\"Synced\")].status"
+//+kubebuilder:printcolumn:name="Reason",type="string",JSONPath=".status.conditions[?(@.type==\"Ready\")].reason"
+//+kubebuilder:printcolumn:name="Last Sync",type="date",JSONPath=".status.lastSyncTime"
+//+kubebuilder:printcolumn:name="Revision",type="integer",JSONPath=".status.currentRevision",priority=1
+//+kubebuilder:printcolumn:name="Next Transition",type="date",JSONPath=".status.upcomingTransitions[0].time",priority=1
+//+kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
 
 // NamespaceLabel is the Schema for the namespacelabels API
 type NamespaceLabel struct {
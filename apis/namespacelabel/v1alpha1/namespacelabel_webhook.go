@@ -17,86 +17,938 @@ limitations under the License.
 package v1alpha1
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
-	"os"
+	"net/http"
+	"sort"
 	"strings"
 
-	"k8s.io/apimachinery/pkg/runtime"
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	configv1alpha1 "home-assignment/apis/config/v1alpha1"
 )
 
 // log is for logging in this package.
 var namespacelabellog = logf.Log.WithName("namespacelabel-resource")
 
-func (r *NamespaceLabel) SetupWebhookWithManager(mgr ctrl.Manager) error {
-	return ctrl.NewWebhookManagedBy(mgr).
-		For(r).
-		Complete()
+// totalAnnotationSizeLimitB is the size limit of all annotations of an object enforced by the api server
+const totalAnnotationSizeLimitB = 256 * (1 << 10)
+
+// namespacelabelClient is used by the webhook to read the cluster policy
+var namespacelabelClient client.Reader
+
+// SetupWebhookWithManager registers the NamespaceLabel webhooks with the webhook server of the manager,
+// the updates of the manager itself, such as the removal of its finalizer, are always allowed
+func (r *NamespaceLabel) SetupWebhookWithManager(mgr ctrl.Manager, managerUsername string) error {
+	namespacelabelClient = mgr.GetClient()
+
+	// the defaulting webhook is registered by hand so it can return admission warnings
+	mgr.GetWebhookServer().Register("/mutate-dana-io-dana-io-v1alpha1-namespacelabel", &webhook.Admission{
+		Handler: &namespaceLabelDefaulter{},
+	})
+
+	// the validating webhook is registered by hand as well, so admins can be told apart from tenants
+	mgr.GetWebhookServer().Register("/validate-dana-io-dana-io-v1alpha1-namespacelabel", &webhook.Admission{
+		Handler: &namespaceLabelValidator{Client: mgr.GetClient(), ManagerUsername: managerUsername},
+	})
+
+	return nil
+}
+
+//+kubebuilder:webhook:path=/mutate-dana-io-dana-io-v1alpha1-namespacelabel,mutating=true,failurePolicy=fail,sideEffects=None,groups=dana.io.dana.io,resources=namespacelabels,verbs=create;update,versions=v1alpha1,name=mnamespacelabel.kb.io,admissionReviewVersions=v1
+//+kubebuilder:rbac:groups=dana.io.dana.io,resources=namespacelabelrevisions,verbs=get;list;watch
+
+// DefaultWithWarnings applies the defaults of the cluster policy and returns a warning
+// for every change, so tenants understand why their object changed
+func (r *NamespaceLabel) DefaultWithWarnings() []string {
+	if namespacelabelClient == nil {
+		return nil
+	}
+
+	config, err := configv1alpha1.GetClusterConfig(context.Background(), namespacelabelClient)
+	if err != nil {
+		namespacelabellog.Error(err, "unable to fetch namespacelabelconfig")
+		return nil
+	}
+
+	return r.ApplyDefaults(&config.Spec.Defaults)
+}
+
+// ApplyDefaults prefixes unprefixed label keys, normalizes label values and adds the
+// default labels of the policy, it returns a warning describing each change
+func (r *NamespaceLabel) ApplyDefaults(defaults *configv1alpha1.NamespacelabelConfigDefaults) []string {
+	warnings := []string{}
+
+	if r.Spec.Labels == nil && len(defaults.Labels) > 0 {
+		r.Spec.Labels = make(map[string]string)
+	}
+
+	if defaults.KeyPrefix != "" {
+		for _, key := range sortedKeys(r.Spec.Labels) {
+			if strings.Contains(key, "/") {
+				continue
+			}
+			prefixed := defaults.KeyPrefix + "/" + key
+			val := r.Spec.Labels[key]
+			delete(r.Spec.Labels, key)
+
+			// an explicitly prefixed key wins over the unprefixed one
+			if _, ok := r.Spec.Labels[prefixed]; ok {
+				warnings = append(warnings, fmt.Sprintf("label %s was dropped since %s is set as well", key, prefixed))
+				continue
+			}
+			r.Spec.Labels[prefixed] = val
+			warnings = append(warnings, fmt.Sprintf("label key %s was changed to %s", key, prefixed))
+		}
+	}
+
+	for _, key := range sortedKeys(r.Spec.Labels) {
+		// templates are normalized once they are rendered, their source would no longer parse
+		if IsTemplate(r.Spec.Labels[key]) {
+			continue
+		}
+		val := defaults.NormalizeValue(r.Spec.Labels[key])
+		if val != r.Spec.Labels[key] {
+			warnings = append(warnings, fmt.Sprintf("value of label %s was changed from %q to %q", key, r.Spec.Labels[key], val))
+			r.Spec.Labels[key] = val
+		}
+	}
+
+	for _, key := range sortedKeys(defaults.Labels) {
+		if _, ok := r.Spec.Labels[key]; ok {
+			continue
+		}
+		val := strings.ReplaceAll(defaults.Labels[key], configv1alpha1.NamespaceVariable, r.Namespace)
+		r.Spec.Labels[key] = val
+		warnings = append(warnings, fmt.Sprintf("label %s=%s was added by cluster policy", key, val))
+	}
+
+	return warnings
+}
+
+// namespaceLabelDefaulter serves the defaulting webhook of NamespaceLabel,
+// it returns the warnings of the defaults along with the patch
+type namespaceLabelDefaulter struct {
+	decoder *admission.Decoder
+}
+
+var _ admission.DecoderInjector = &namespaceLabelDefaulter{}
+
+// Handle applies the defaults to the NamespaceLabel in the request
+func (h *namespaceLabelDefaulter) Handle(ctx context.Context, req admission.Request) admission.Response {
+	namespaceLabel := &NamespaceLabel{}
+	if err := h.decoder.Decode(req, namespaceLabel); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+
+	var oldNamespaceLabel *NamespaceLabel
+	if req.Operation == admissionv1.Update {
+		oldNamespaceLabel = &NamespaceLabel{}
+		if err := h.decoder.DecodeRaw(req.OldObject, oldNamespaceLabel); err != nil {
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+
+		// updates that leave the spec alone, such as the finalizer changes of the controller, and
+		// updates of a NamespaceLabel that is being deleted are not defaulted, so a locked
+		// NamespaceLabel that no longer matches the defaults can still go away
+		if !namespaceLabel.DeletionTimestamp.IsZero() || equality.Semantic.DeepEqual(oldNamespaceLabel.Spec, namespaceLabel.Spec) {
+			return admission.Allowed("")
+		}
+	}
+
+	// a rollback is resolved in the request of the user, so the restored keys are validated
+	// and authorized against that user like any other change
+	if err := namespaceLabel.resolveRollback(ctx, namespacelabelClient, oldNamespaceLabel); err != nil {
+		var apiStatus apierrors.APIStatus
+		if errors.As(err, &apiStatus) {
+			status := apiStatus.Status()
+			return admission.Response{AdmissionResponse: admissionv1.AdmissionResponse{Allowed: false, Result: &status}}
+		}
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+
+	namespacelabellog.Info("default", "name", namespaceLabel.Name)
+	warnings := namespaceLabel.DefaultWithWarnings()
+
+	// record who changed the spec, so the revision of the change names its author
+	namespaceLabel.setChangedBy(oldNamespaceLabel, req.UserInfo.Username)
+
+	marshalled, err := json.Marshal(namespaceLabel)
+	if err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+
+	return admission.PatchResponseFromRaw(req.Object.Raw, marshalled).WithWarnings(warnings...)
+}
+
+// InjectDecoder implements admission.DecoderInjector so the webhook server provides the decoder
+func (h *namespaceLabelDefaulter) InjectDecoder(d *admission.Decoder) error {
+	h.decoder = d
+	return nil
+}
+
+// resolveRollback replaces the labels and annotations with the ones of the revision in spec.rollbackTo
+// and clears the field. A revision belongs to the stored NamespaceLabel, so a rollback can not be
+// requested when the NamespaceLabel is created
+func (r *NamespaceLabel) resolveRollback(ctx context.Context, c client.Reader, old *NamespaceLabel) error {
+	if r.Spec.RollbackTo == nil {
+		return nil
+	}
+	fldPath := field.NewPath("spec", "rollbackTo")
+	number := *r.Spec.RollbackTo
+
+	if old == nil {
+		return toInvalidError(r.Name, field.ErrorList{field.Forbidden(fldPath, "a NamespaceLabel without revisions can not be rolled back")})
+	}
+	if c == nil {
+		return toInvalidError(r.Name, field.ErrorList{field.NotFound(fldPath, number)})
+	}
+
+	revision, err := GetRevision(ctx, c, old, number)
+	if err != nil {
+		namespacelabellog.Error(err, "unable to fetch namespaceLabelRevision", "revision", number)
+		return err
+	}
+	if revision == nil {
+		return toInvalidError(r.Name, field.ErrorList{field.NotFound(fldPath, number)})
+	}
+
+	namespacelabellog.Info("rollback", "name", r.Name, "revision", number)
+	r.Spec.Labels = copyKeys(revision.Spec.Labels)
+	r.Spec.Annotations = copyKeys(revision.Spec.Annotations)
+	r.Spec.RollbackTo = nil
+
+	return nil
+}
+
+// copyKeys returns a copy of the labels or annotations, or nil when there are none
+func copyKeys(keys map[string]string) map[string]string {
+	if len(keys) == 0 {
+		return nil
+	}
+
+	copied := make(map[string]string, len(keys))
+	for key, val := range keys {
+		copied[key] = val
+	}
+
+	return copied
+}
+
+// setChangedBy records the user who changed the spec in the changed-by annotation,
+// updates that leave the spec alone keep the recorded user
+func (r *NamespaceLabel) setChangedBy(old *NamespaceLabel, username string) {
+	changedBy := username
+	if old != nil && equality.Semantic.DeepEqual(old.Spec, r.Spec) {
+		changedBy = old.Annotations[ChangedByAnnotation]
+	}
+
+	if changedBy == "" {
+		delete(r.Annotations, ChangedByAnnotation)
+		return
+	}
+	if r.Annotations == nil {
+		r.Annotations = make(map[string]string)
+	}
+	r.Annotations[ChangedByAnnotation] = changedBy
+}
+
+// sortedKeys returns the keys of the map in a deterministic order
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
 }
 
 //+kubebuilder:webhook:path=/validate-dana-io-dana-io-v1alpha1-namespacelabel,mutating=false,failurePolicy=fail,sideEffects=None,groups=dana.io.dana.io,resources=namespacelabels,verbs=create;update;delete,versions=v1alpha1,name=vnamespacelabel.kb.io,admissionReviewVersions=v1
 //+kubebuilder:rbac:groups=*,resources=namespaces,verbs=get;list
+//+kubebuilder:rbac:groups=config.dana.io,resources=namespacelabelconfigs,verbs=get;list;watch
+//+kubebuilder:rbac:groups=authorization.k8s.io,resources=subjectaccessreviews,verbs=create
+
+// validateUpdate compares the NamespaceLabel with its old version, only admins may
+// change the spec of a locked NamespaceLabel or the value of an immutable key
+func (r *NamespaceLabel) validateUpdate(old *NamespaceLabel, admin bool) error {
+	// metadata changes such as finalizers are always allowed, so a NamespaceLabel that no
+	// longer complies with a changed policy can still be cleaned up
+	if equality.Semantic.DeepEqual(old.Spec, r.Spec) {
+		return nil
+	}
+
+	if admin {
+		return r.CheckLabelNS(old)
+	}
+
+	allErrs := field.ErrorList{}
+	if old.Spec.Locked && !equality.Semantic.DeepEqual(old.Spec, r.Spec) {
+		allErrs = append(allErrs, field.Forbidden(field.NewPath("spec"), "NamespaceLabel is locked, only an admin may change it"))
+	}
+	allErrs = append(allErrs, validateImmutableKeys(old, r)...)
+	if len(allErrs) > 0 {
+		return toInvalidError(r.Name, allErrs)
+	}
 
-var _ webhook.Validator = &NamespaceLabel{}
+	return r.CheckLabelNS(old)
+}
+
+// validateDelete rejects the deletion of a locked NamespaceLabel unless by an admin,
+// or when its namespace is being deleted since the NamespaceLabel has to go with it
+func (r *NamespaceLabel) validateDelete(admin bool) error {
+	if admin || !r.Spec.Locked {
+		return nil
+	}
+
+	if namespacelabelClient != nil {
+		namespace := &v1.Namespace{}
+		if err := namespacelabelClient.Get(context.Background(), types.NamespacedName{Name: r.Namespace}, namespace); err != nil && !apierrors.IsNotFound(err) {
+			return err
+		} else if err != nil || !namespace.DeletionTimestamp.IsZero() {
+			return nil
+		}
+	}
+
+	return apierrors.NewForbidden(GroupVersion.WithResource("namespacelabels").GroupResource(), r.Name, fmt.Errorf("NamespaceLabel is locked, only an admin may delete it"))
+}
+
+// validateImmutableKeys returns an error for every immutable key of the old NamespaceLabel
+// that was changed or removed, and for every key removed from the immutable keys
+func validateImmutableKeys(old *NamespaceLabel, r *NamespaceLabel) field.ErrorList {
+	allErrs := field.ErrorList{}
 
-// ValidateCreate implements webhook.Validator so a webhook will be registered for the type
-func (r *NamespaceLabel) ValidateCreate() error {
-	namespacelabellog.Info("validate create", "name", r.Name)
+	immutableKeys := make(map[string]bool)
+	for _, key := range r.Spec.ImmutableKeys {
+		immutableKeys[key] = true
+	}
 
-	if res := r.CheckNamespaceLabelName(); !res {
-		err := fmt.Errorf("NamespaceLabel name must be equal to the name of its namespace")
-		namespacelabellog.Error(err, "unable to crate namespacelabel")
+	for i, key := range old.Spec.ImmutableKeys {
+		if !immutableKeys[key] {
+			allErrs = append(allErrs, field.Forbidden(field.NewPath("spec", "immutableKeys").Index(i), fmt.Sprintf("key %s can not be made mutable again", key)))
+		}
+		if val, ok := old.Spec.Labels[key]; ok && r.Spec.Labels[key] != val {
+			allErrs = append(allErrs, field.Forbidden(field.NewPath("spec", "labels").Key(key), "label is immutable once set"))
+		}
+		if val, ok := old.Spec.Annotations[key]; ok && r.Spec.Annotations[key] != val {
+			allErrs = append(allErrs, field.Forbidden(field.NewPath("spec", "annotations").Key(key), "annotation is immutable once set"))
+		}
+	}
+
+	return allErrs
+}
+
+// validateKeyPermissions returns an error for every key the user sets without being granted
+// the permissions of the cluster policy. Keys that are unchanged from the old NamespaceLabel
+// are not checked, so tenants can still edit other keys next to a restricted one
+func (r *NamespaceLabel) validateKeyPermissions(ctx context.Context, old *NamespaceLabel, user authenticationv1.UserInfo) error {
+	if namespacelabelClient == nil {
+		return nil
+	}
 
+	config, err := configv1alpha1.GetClusterConfig(ctx, namespacelabelClient)
+	if err != nil {
+		namespacelabellog.Error(err, "unable to fetch namespacelabelconfig")
 		return err
 	}
 
-	if err := r.CheckLabelNS(); err != nil {
+	oldSpec := NamespaceLabelSpec{}
+	if old != nil {
+		oldSpec = old.Spec
+	}
+
+	allErrs := field.ErrorList{}
+	allErrs = append(allErrs, checkKeyPermissions(&config.Spec, field.NewPath("spec", "labels"), oldSpec.Labels, r.Spec.Labels, user)...)
+	allErrs = append(allErrs, checkKeyPermissions(&config.Spec, field.NewPath("spec", "annotations"), oldSpec.Annotations, r.Spec.Annotations, user)...)
+
+	return toInvalidError(r.Name, allErrs)
+}
+
+// checkKeyPermissions returns an error for every added or changed key the user may not set
+func checkKeyPermissions(spec *configv1alpha1.NamespacelabelConfigSpec, fldPath *field.Path, oldKeys map[string]string, keys map[string]string, user authenticationv1.UserInfo) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	for _, key := range sortedKeys(keys) {
+		if oldVal, ok := oldKeys[key]; ok && oldVal == keys[key] {
+			continue
+		}
+		if err := spec.CheckKeyPermission(key, user); err != nil {
+			allErrs = append(allErrs, field.Forbidden(fldPath.Key(key), err.Error()))
+		}
+	}
+
+	return allErrs
+}
+
+// authorizeKeys issues a SubjectAccessReview on the virtual namespacelabels/keys resource for every
+// key the user adds, changes or removes, when the cluster policy requires it. The verb of each review
+// is create, update or delete, and the resource name is the key
+func (r *NamespaceLabel) authorizeKeys(ctx context.Context, c client.Client, old *NamespaceLabel, user authenticationv1.UserInfo) error {
+	if namespacelabelClient == nil || c == nil {
+		return nil
+	}
+
+	config, err := configv1alpha1.GetClusterConfig(ctx, namespacelabelClient)
+	if err != nil {
+		namespacelabellog.Error(err, "unable to fetch namespacelabelconfig")
 		return err
 	}
+	if !config.Spec.AuthorizeKeys {
+		return nil
+	}
 
-	return nil
+	oldSpec := NamespaceLabelSpec{}
+	if old != nil {
+		oldSpec = old.Spec
+	}
+
+	extra := make(map[string]authorizationv1.ExtraValue, len(user.Extra))
+	for key, val := range user.Extra {
+		extra[key] = authorizationv1.ExtraValue(val)
+	}
+
+	allErrs := field.ErrorList{}
+	for _, keys := range []struct {
+		fldPath *field.Path
+		changes map[string]string
+	}{
+		{field.NewPath("spec", "labels"), getKeyChanges(oldSpec.Labels, r.Spec.Labels)},
+		{field.NewPath("spec", "annotations"), getKeyChanges(oldSpec.Annotations, r.Spec.Annotations)},
+	} {
+		for _, key := range sortedKeys(keys.changes) {
+			verb := keys.changes[key]
+			review := &authorizationv1.SubjectAccessReview{
+				Spec: authorizationv1.SubjectAccessReviewSpec{
+					ResourceAttributes: &authorizationv1.ResourceAttributes{
+						Namespace:   r.Namespace,
+						Verb:        verb,
+						Group:       GroupVersion.Group,
+						Resource:    "namespacelabels",
+						Subresource: "keys",
+						Name:        key,
+					},
+					User:   user.Username,
+					Groups: user.Groups,
+					UID:    user.UID,
+					Extra:  extra,
+				},
+			}
+			if err := c.Create(ctx, review); err != nil {
+				namespacelabellog.Error(err, "unable to create subjectaccessreview", "key", key)
+				return err
+			}
+			if !review.Status.Allowed {
+				allErrs = append(allErrs, field.Forbidden(keys.fldPath.Key(key), fmt.Sprintf("user %s may not %s key %s in namespace %s", user.Username, verb, key, r.Namespace)))
+			}
+		}
+	}
+
+	return toInvalidError(r.Name, allErrs)
+}
+
+// getKeyChanges returns the keys that were added, changed or removed between the old and the
+// new keys, along with the RBAC verb of the change
+func getKeyChanges(oldKeys map[string]string, keys map[string]string) map[string]string {
+	changes := make(map[string]string)
+
+	for key, val := range keys {
+		oldVal, ok := oldKeys[key]
+		if !ok {
+			changes[key] = "create"
+		} else if oldVal != val {
+			changes[key] = "update"
+		}
+	}
+	for key := range oldKeys {
+		if _, ok := keys[key]; !ok {
+			changes[key] = "delete"
+		}
+	}
+
+	return changes
 }
 
-// ValidateUpdate implements webhook.Validator so a webhook will be registered for the type
-func (r *NamespaceLabel) ValidateUpdate(old runtime.Object) error {
-	namespacelabellog.Info("validate update", "name", r.Name)
+// namespaceLabelValidator serves the validating webhook of NamespaceLabel, it is the only place the policy
+// is enforced since it knows the requesting user. Members of the admin groups bypass locks and immutable
+// keys, and keys restricted by the cluster policy can only be set by the users it grants them to
+type namespaceLabelValidator struct {
+	// Client creates the SubjectAccessReviews of the keys
+	Client client.Client
 
-	return r.CheckLabelNS()
+	// Username of the manager, whose own updates are always allowed
+	ManagerUsername string
+
+	decoder *admission.Decoder
 }
 
-// ValidateDelete implements webhook.Validator so a webhook will be registered for the type
-func (r *NamespaceLabel) ValidateDelete() error {
-	namespacelabellog.Info("validate delete", "name", r.Name)
+var _ admission.DecoderInjector = &namespaceLabelValidator{}
+
+// Handle validates the NamespaceLabel in the request
+func (h *namespaceLabelValidator) Handle(ctx context.Context, req admission.Request) admission.Response {
+	namespaceLabel := &NamespaceLabel{}
 
+	var err error
+	var warnings []string
+	switch req.Operation {
+	case admissionv1.Create:
+		if err := h.decoder.Decode(req, namespaceLabel); err != nil {
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+		namespacelabellog.Info("validate create", "name", namespaceLabel.Name, "user", req.UserInfo.Username)
+		err = namespaceLabel.CheckLabelNS(nil)
+		if err == nil {
+			err = namespaceLabel.validateKeyPermissions(ctx, nil, req.UserInfo)
+		}
+		if err == nil {
+			err = namespaceLabel.authorizeKeys(ctx, h.Client, nil, req.UserInfo)
+		}
+		if err == nil {
+			warnings, err = namespaceLabel.checkKeyConflicts(ctx)
+		}
+	case admissionv1.Update:
+		oldNamespaceLabel := &NamespaceLabel{}
+		if err := h.decoder.DecodeRaw(req.Object, namespaceLabel); err != nil {
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+		if err := h.decoder.DecodeRaw(req.OldObject, oldNamespaceLabel); err != nil {
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+		namespacelabellog.Info("validate update", "name", namespaceLabel.Name, "user", req.UserInfo.Username)
+		if h.ManagerUsername != "" && req.UserInfo.Username == h.ManagerUsername {
+			return admission.Allowed("")
+		}
+		err = namespaceLabel.validateUpdate(oldNamespaceLabel, isAdmin(ctx, req.UserInfo.Groups))
+		if err == nil {
+			err = namespaceLabel.validateKeyPermissions(ctx, oldNamespaceLabel, req.UserInfo)
+		}
+		if err == nil {
+			err = namespaceLabel.authorizeKeys(ctx, h.Client, oldNamespaceLabel, req.UserInfo)
+		}
+		// metadata changes such as finalizers are not checked, like in validateUpdate
+		if err == nil && !equality.Semantic.DeepEqual(oldNamespaceLabel.Spec, namespaceLabel.Spec) {
+			warnings, err = namespaceLabel.checkKeyConflicts(ctx)
+		}
+	case admissionv1.Delete:
+		// the old object holds the NamespaceLabel that is being deleted
+		if err := h.decoder.DecodeRaw(req.OldObject, namespaceLabel); err != nil {
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+		namespacelabellog.Info("validate delete", "name", namespaceLabel.Name, "user", req.UserInfo.Username)
+		err = namespaceLabel.validateDelete(isAdmin(ctx, req.UserInfo.Groups))
+		// the deletion removes every key of the NamespaceLabel, so each one needs the delete verb
+		if err == nil {
+			removed := &NamespaceLabel{ObjectMeta: namespaceLabel.ObjectMeta}
+			err = removed.authorizeKeys(ctx, h.Client, namespaceLabel, req.UserInfo)
+		}
+	default:
+		return admission.Errored(http.StatusBadRequest, fmt.Errorf("unknown operation request %q", req.Operation))
+	}
+
+	if err != nil {
+		var apiStatus apierrors.APIStatus
+		if errors.As(err, &apiStatus) {
+			status := apiStatus.Status()
+			return admission.Response{AdmissionResponse: admissionv1.AdmissionResponse{Allowed: false, Result: &status}}
+		}
+		return admission.Denied(err.Error())
+	}
+
+	return admission.Allowed("").WithWarnings(warnings...)
+}
+
+// InjectDecoder implements admission.DecoderInjector so the webhook server provides the decoder
+func (h *namespaceLabelValidator) InjectDecoder(d *admission.Decoder) error {
+	h.decoder = d
 	return nil
 }
 
-func (r *NamespaceLabel) CheckNamespaceLabelName() bool {
-	nsLabelName := r.Name
-	nsLabelNamespace := r.Namespace
+// isAdmin reports whether one of the groups is an admin group of the cluster policy
+func isAdmin(ctx context.Context, groups []string) bool {
+	if namespacelabelClient == nil {
+		return false
+	}
 
-	return nsLabelName == nsLabelNamespace
+	config, err := configv1alpha1.GetClusterConfig(ctx, namespacelabelClient)
+	if err != nil {
+		namespacelabellog.Error(err, "unable to fetch namespacelabelconfig")
+		return false
+	}
+
+	for _, group := range groups {
+		for _, admin := range config.Spec.AdminGroups {
+			if group == admin {
+				return true
+			}
+		}
+	}
+
+	return false
 }
 
-func (r *NamespaceLabel) CheckLabelNS() error {
-	// get controller config map values from environment variable
-	controllerConfigMapKey := os.Getenv("PROTECTED_MANAGEMENT_LABELS_DOMAINS")
+// CheckLabelNS validates the keys of the NamespaceLabel against their syntax and the cluster policy,
+// on update the limits only reject keys that grow beyond those of the old NamespaceLabel
+func (r *NamespaceLabel) CheckLabelNS(old *NamespaceLabel) error {
+	// templated values are checked as they render on the namespace, normalized by the defaults
+	// of the cluster policy like the controller does
+	namespace := &v1.Namespace{}
+	namespace.Name = r.Namespace
+	config := &configv1alpha1.NamespacelabelConfig{}
+	if namespacelabelClient != nil {
+		if err := namespacelabelClient.Get(context.Background(), types.NamespacedName{Name: r.Namespace}, namespace); err != nil && !apierrors.IsNotFound(err) {
+			namespacelabellog.Error(err, "unable to fetch namespace")
+			return err
+		}
 
-	if controllerConfigMapKey != "" {
+		// get the cluster policy, it is read on every request so changes are picked up live
+		var err error
+		if config, err = configv1alpha1.GetClusterConfig(context.Background(), namespacelabelClient); err != nil {
+			namespacelabellog.Error(err, "unable to fetch namespacelabelconfig")
+			return err
+		}
+	}
+	labels, allErrs := renderTemplates(field.NewPath("spec", "labels"), r.Spec.Labels, namespace, config.Spec.Defaults.NormalizeValue)
+	annotations, errs := renderTemplates(field.NewPath("spec", "annotations"), r.Spec.Annotations, namespace, nil)
+	allErrs = append(allErrs, errs...)
 
-		protectedDomains := strings.Split(controllerConfigMapKey, ",")
+	// collect every violation so the user can fix them all at once
+	allErrs = append(allErrs, ValidateLabelSyntax(field.NewPath("spec", "labels"), labels)...)
+	allErrs = append(allErrs, ValidateAnnotationSyntax(field.NewPath("spec", "annotations"), annotations)...)
 
-		for key := range r.Spec.Labels {
-			reqlabelDomain := strings.Split(key, "/")[0]
-			for _, dom := range protectedDomains {
-				if strings.HasSuffix(reqlabelDomain, dom) {
-					errorMsg := fmt.Errorf("setting labels of the %s domain is not allowed", dom)
-					return errorMsg
-				}
+	// only the labels of the NamespaceLabel itself can be propagated
+	if r.Spec.Propagation != nil {
+		for i, key := range r.Spec.Propagation.Keys {
+			if _, ok := r.Spec.Labels[key]; !ok {
+				allErrs = append(allErrs, field.Invalid(field.NewPath("spec", "propagation", "keys").Index(i), key, "key is not one of the labels of the NamespaceLabel"))
 			}
 		}
 	}
-	return nil
+
+	allErrs = append(allErrs, r.validateSchedules()...)
+	allErrs = append(allErrs, r.validateKeyDeletionPolicies()...)
+
+	if namespacelabelClient == nil {
+		return toInvalidError(r.Name, allErrs)
+	}
+
+	allErrs = append(allErrs, config.Spec.ValidateLabels(field.NewPath("spec", "labels"), labels)...)
+
+	// annotation keys are subject to the same protected domains and key patterns
+	allErrs = append(allErrs, config.Spec.ValidateAnnotations(field.NewPath("spec", "annotations"), annotations)...)
+
+	// a NamespaceLabel over a lowered limit may still be updated as long as it does not grow
+	if err := config.Spec.CheckLabelsLimit(r.Namespace, labels); err != nil && (old == nil || len(labels) > len(old.Spec.Labels)) {
+		allErrs = append(allErrs, field.TooMany(field.NewPath("spec", "labels"), len(labels), int(*config.Spec.LimitsFor(r.Namespace).MaxLabelsPerObject)))
+	}
+
+	// the limits of the namespace count the keys of every source managing it
+	namespaceLabels := &NamespaceLabelList{}
+	if err := namespacelabelClient.List(context.Background(), namespaceLabels, client.InNamespace(r.Namespace)); err != nil {
+		namespacelabellog.Error(err, "unable to list namespaceLabels")
+		return err
+	}
+	clusterNamespaceLabels := &ClusterNamespaceLabelList{}
+	if err := namespacelabelClient.List(context.Background(), clusterNamespaceLabels); err != nil {
+		namespacelabellog.Error(err, "unable to list clusterNamespaceLabels")
+		return err
+	}
+	// the limits count the keys as they render on the namespace, like the controller does
+	rendered := r.DeepCopy()
+	rendered.Spec.Labels, rendered.Spec.Annotations = labels, annotations
+	managedLabels, managedAnnotations := rendered.GetManagedKeys(namespaceLabels.Items, clusterNamespaceLabels.Items)
+	var oldLabels, oldAnnotations map[string]string
+	if old != nil {
+		oldLabels, oldAnnotations = old.GetManagedKeys(namespaceLabels.Items, clusterNamespaceLabels.Items)
+	}
+	for _, err := range config.Spec.CheckNamespaceLimitsGrowth(r.Namespace, managedLabels, managedAnnotations, oldLabels, oldAnnotations) {
+		allErrs = append(allErrs, field.Forbidden(field.NewPath("spec"), err.Error()))
+	}
+
+	// a template reading a managed key could read its own output and change on every sync
+	allErrs = append(allErrs, validateTemplateReferences(field.NewPath("spec", "labels"), r.Spec.Labels, "Labels", managedLabels)...)
+	allErrs = append(allErrs, validateTemplateReferences(field.NewPath("spec", "annotations"), r.Spec.Annotations, "Annotations", managedAnnotations)...)
+
+	return toInvalidError(r.Name, allErrs)
+}
+
+// GetManagedKeys returns the labels and annotations managed on the namespace of the NamespaceLabel
+// by all sources together, counting the spec of the NamespaceLabel in place of its stored version
+func (r *NamespaceLabel) GetManagedKeys(namespaceLabels []NamespaceLabel, clusterNamespaceLabels []ClusterNamespaceLabel) (map[string]string, map[string]string) {
+	labels := make(map[string]string)
+	annotations := make(map[string]string)
+
+	add := func(dst map[string]string, src map[string]string) {
+		for key, val := range src {
+			dst[key] = val
+		}
+	}
+
+	for _, clusterNamespaceLabel := range clusterNamespaceLabels {
+		for _, target := range clusterNamespaceLabel.Status.Targets {
+			if target.Name == r.Namespace {
+				add(labels, target.ActiveLabels)
+				add(annotations, target.ActiveAnnotations)
+			}
+		}
+	}
+
+	for _, namespaceLabel := range namespaceLabels {
+		if namespaceLabel.Name == r.Name || !namespaceLabel.DeletionTimestamp.IsZero() {
+			continue
+		}
+		add(labels, namespaceLabel.Spec.Labels)
+		add(annotations, namespaceLabel.Spec.Annotations)
+	}
+
+	add(labels, r.Spec.Labels)
+	add(annotations, r.Spec.Annotations)
+
+	return labels, annotations
+}
+
+// checkKeyConflicts compares the keys of the NamespaceLabel with the keys of the other sources
+// managing its namespace, as read from the cache of the manager. Depending on the cluster policy
+// colliding keys reject the NamespaceLabel or are returned as warnings
+func (r *NamespaceLabel) checkKeyConflicts(ctx context.Context) ([]string, error) {
+	if namespacelabelClient == nil {
+		return nil, nil
+	}
+
+	config, err := configv1alpha1.GetClusterConfig(ctx, namespacelabelClient)
+	if err != nil {
+		namespacelabellog.Error(err, "unable to fetch namespacelabelconfig")
+		return nil, err
+	}
+
+	namespaceLabels := &NamespaceLabelList{}
+	if err := namespacelabelClient.List(ctx, namespaceLabels, client.InNamespace(r.Namespace)); err != nil {
+		namespacelabellog.Error(err, "unable to list namespaceLabels")
+		return nil, err
+	}
+
+	// ClusterNamespaceLabels are matched against the namespace, a missing namespace has none
+	clusterNamespaceLabels := &ClusterNamespaceLabelList{}
+	namespace := &v1.Namespace{}
+	if err := namespacelabelClient.Get(ctx, types.NamespacedName{Name: r.Namespace}, namespace); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return nil, err
+		}
+		namespace = nil
+	} else if err := namespacelabelClient.List(ctx, clusterNamespaceLabels); err != nil {
+		namespacelabellog.Error(err, "unable to list clusterNamespaceLabels")
+		return nil, err
+	}
+
+	allErrs := r.getKeyConflicts(namespace, namespaceLabels.Items, clusterNamespaceLabels.Items)
+	if config.Spec.KeyConflicts == configv1alpha1.KeyConflictReject {
+		return nil, toInvalidError(r.Name, allErrs)
+	}
+
+	warnings := make([]string, len(allErrs))
+	for i, err := range allErrs {
+		warnings[i] = err.Error()
+	}
+
+	return warnings, nil
+}
+
+// getKeyConflicts returns an error naming the conflicting object for every key of the NamespaceLabel
+// that another NamespaceLabel of the namespace, or a ClusterNamespaceLabel selecting the namespace,
+// sets to a different value. Sources setting the same value do not conflict
+func (r *NamespaceLabel) getKeyConflicts(namespace *v1.Namespace, namespaceLabels []NamespaceLabel, clusterNamespaceLabels []ClusterNamespaceLabel) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	check := func(fldPath *field.Path, keys map[string]string, otherKeys map[string]string, owner string) {
+		for _, key := range sortedKeys(keys) {
+			if val, ok := otherKeys[key]; ok && val != keys[key] {
+				allErrs = append(allErrs, field.Forbidden(fldPath.Key(key), fmt.Sprintf("key is set to %q by %s", val, owner)))
+			}
+		}
+	}
+
+	if namespace != nil {
+		for i := range clusterNamespaceLabels {
+			clusterNamespaceLabel := &clusterNamespaceLabels[i]
+			if !clusterNamespaceLabel.DeletionTimestamp.IsZero() {
+				continue
+			}
+			if matches, err := clusterNamespaceLabel.MatchesNamespace(namespace.Name, namespace.Labels); err != nil || !matches {
+				continue
+			}
+			owner := fmt.Sprintf("ClusterNamespaceLabel %s", clusterNamespaceLabel.Name)
+			check(field.NewPath("spec", "labels"), r.Spec.Labels, clusterNamespaceLabel.Spec.Labels, owner)
+			check(field.NewPath("spec", "annotations"), r.Spec.Annotations, clusterNamespaceLabel.Spec.Annotations, owner)
+		}
+	}
+
+	for i := range namespaceLabels {
+		namespaceLabel := &namespaceLabels[i]
+		if namespaceLabel.Name == r.Name || !namespaceLabel.DeletionTimestamp.IsZero() {
+			continue
+		}
+		owner := fmt.Sprintf("NamespaceLabel %s/%s", namespaceLabel.Namespace, namespaceLabel.Name)
+		check(field.NewPath("spec", "labels"), r.Spec.Labels, namespaceLabel.Spec.Labels, owner)
+		check(field.NewPath("spec", "annotations"), r.Spec.Annotations, namespaceLabel.Spec.Annotations, owner)
+	}
+
+	return allErrs
+}
+
+// validateSchedules checks every schedule applies to a key of the NamespaceLabel, at most one
+// schedule applies to each key and the window of the schedule ends after it starts
+func (r *NamespaceLabel) validateSchedules() field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	scheduled := make(map[string]bool)
+	for i, schedule := range r.Spec.Schedules {
+		fldPath := field.NewPath("spec", "schedules").Index(i)
+
+		_, isLabel := r.Spec.Labels[schedule.Key]
+		_, isAnnotation := r.Spec.Annotations[schedule.Key]
+		if !isLabel && !isAnnotation {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("key"), schedule.Key, "key is not one of the labels or annotations of the NamespaceLabel"))
+		}
+		if scheduled[schedule.Key] {
+			allErrs = append(allErrs, field.Duplicate(fldPath.Child("key"), schedule.Key))
+		}
+		scheduled[schedule.Key] = true
+
+		if schedule.NotBefore != nil && schedule.ExpiresAt != nil && !schedule.ExpiresAt.After(schedule.NotBefore.Time) {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("expiresAt"), schedule.ExpiresAt.String(), "must be after notBefore"))
+		}
+	}
+
+	return allErrs
+}
+
+// validateKeyDeletionPolicies checks every key deletion policy applies to a key of the
+// NamespaceLabel and at most one policy applies to each key
+func (r *NamespaceLabel) validateKeyDeletionPolicies() field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	seen := make(map[string]bool)
+	for i, keyPolicy := range r.Spec.KeyDeletionPolicies {
+		fldPath := field.NewPath("spec", "keyDeletionPolicies").Index(i)
+
+		_, isLabel := r.Spec.Labels[keyPolicy.Key]
+		_, isAnnotation := r.Spec.Annotations[keyPolicy.Key]
+		if !isLabel && !isAnnotation {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("key"), keyPolicy.Key, "key is not one of the labels or annotations of the NamespaceLabel"))
+		}
+		if seen[keyPolicy.Key] {
+			allErrs = append(allErrs, field.Duplicate(fldPath.Child("key"), keyPolicy.Key))
+		}
+		seen[keyPolicy.Key] = true
+	}
+
+	return allErrs
+}
+
+// validateTemplateReferences checks that no templated value reads a label or annotation of the
+// namespace, depending on the field, that is managed by a NamespaceLabel or ClusterNamespaceLabel
+func validateTemplateReferences(fldPath *field.Path, keys map[string]string, metadataField string, managedKeys map[string]string) field.ErrorList {
+	allErrs := field.ErrorList{}
+	kind := strings.ToLower(strings.TrimSuffix(metadataField, "s"))
+
+	for _, key := range sortedKeys(keys) {
+		if !IsTemplate(keys[key]) {
+			continue
+		}
+		// templates that do not parse are reported when they are rendered
+		refs, whole, err := ReferencedKeys(keys[key], metadataField)
+		if err != nil {
+			continue
+		}
+		if whole && len(managedKeys) > 0 {
+			allErrs = append(allErrs, field.Invalid(fldPath.Key(key), keys[key], fmt.Sprintf("template reads every %s of the namespace, including managed ones", kind)))
+			continue
+		}
+		for _, ref := range refs {
+			if _, ok := managedKeys[ref]; ok {
+				allErrs = append(allErrs, field.Invalid(fldPath.Key(key), keys[key], fmt.Sprintf("template reads %s %s, which is managed by a NamespaceLabel or ClusterNamespaceLabel", kind, ref)))
+			}
+		}
+	}
+
+	return allErrs
+}
+
+// renderTemplates renders the templated values from the metadata of the namespace, returning
+// the rendered keys and an error for every template that fails to parse or render. Rendered
+// values are passed through normalize when it is set
+func renderTemplates(fldPath *field.Path, keys map[string]string, namespace *v1.Namespace, normalize func(string) string) (map[string]string, field.ErrorList) {
+	allErrs := field.ErrorList{}
+	rendered := make(map[string]string, len(keys))
+
+	for _, key := range sortedKeys(keys) {
+		val, err := RenderValue(keys[key], namespace)
+		if err != nil {
+			allErrs = append(allErrs, field.Invalid(fldPath.Key(key), keys[key], fmt.Sprintf("invalid template: %v", err)))
+			continue
+		}
+		if normalize != nil && IsTemplate(keys[key]) {
+			val = normalize(val)
+		}
+		rendered[key] = val
+	}
+
+	return rendered, allErrs
+}
+
+// ValidateLabelSyntax checks every label key is a qualified name and every value a valid label value
+func ValidateLabelSyntax(fldPath *field.Path, labels map[string]string) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	for _, key := range sortedKeys(labels) {
+		for _, msg := range validation.IsQualifiedName(key) {
+			allErrs = append(allErrs, field.Invalid(fldPath.Key(key), key, msg))
+		}
+		for _, msg := range validation.IsValidLabelValue(labels[key]) {
+			allErrs = append(allErrs, field.Invalid(fldPath.Key(key), labels[key], msg))
+		}
+	}
+
+	return allErrs
+}
+
+// ValidateAnnotationSyntax checks every annotation key is a qualified name and the annotations
+// fit in the size limit of the namespace metadata
+func ValidateAnnotationSyntax(fldPath *field.Path, annotations map[string]string) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	totalSize := 0
+	for _, key := range sortedKeys(annotations) {
+		for _, msg := range validation.IsQualifiedName(strings.ToLower(key)) {
+			allErrs = append(allErrs, field.Invalid(fldPath.Key(key), key, msg))
+		}
+		totalSize += len(key) + len(annotations[key])
+	}
+	if totalSize > totalAnnotationSizeLimitB {
+		allErrs = append(allErrs, field.TooLong(fldPath, "", totalAnnotationSizeLimitB))
+	}
+
+	return allErrs
+}
+
+// toInvalidError aggregates the field errors into a single Invalid error, or returns nil
+func toInvalidError(name string, allErrs field.ErrorList) error {
+	if len(allErrs) == 0 {
+		return nil
+	}
+
+	return apierrors.NewInvalid(GroupVersion.WithKind("NamespaceLabel").GroupKind(), name, allErrs)
 }
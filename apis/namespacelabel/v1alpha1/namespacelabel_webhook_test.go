@@ -0,0 +1,734 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/utils/pointer"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	configv1alpha1 "home-assignment/apis/config/v1alpha1"
+)
+
+func TestApplyDefaults(t *testing.T) {
+	namespaceLabel := &NamespaceLabel{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "defaults",
+			Namespace: "team-a",
+		},
+		Spec: NamespaceLabelSpec{
+			Labels: map[string]string{
+				"app":                 "Web ",
+				"env":                 "dev",
+				"tenant.dana.io/env":  "prod",
+				"example.com/version": "v1",
+				"example.com/owner":   "{{ .Namespace.Annotations.Owner }}",
+			},
+		},
+	}
+
+	defaults := &configv1alpha1.NamespacelabelConfigDefaults{
+		Labels: map[string]string{
+			"tenant": configv1alpha1.NamespaceVariable,
+		},
+		KeyPrefix:       "tenant.dana.io",
+		TrimValues:      true,
+		LowercaseValues: true,
+	}
+
+	warnings := namespaceLabel.ApplyDefaults(defaults)
+
+	expectedLabels := map[string]string{
+		"tenant.dana.io/app":  "web",
+		"tenant.dana.io/env":  "prod",
+		"example.com/version": "v1",
+		"example.com/owner":   "{{ .Namespace.Annotations.Owner }}",
+		"tenant":              "team-a",
+	}
+	if !reflect.DeepEqual(namespaceLabel.Spec.Labels, expectedLabels) {
+		t.Errorf("ApplyDefaults set labels %v, expected %v", namespaceLabel.Spec.Labels, expectedLabels)
+	}
+
+	expectedWarnings := []string{
+		"label key app was changed to tenant.dana.io/app",
+		"label env was dropped since tenant.dana.io/env is set as well",
+		"value of label tenant.dana.io/app was changed from \"Web \" to \"web\"",
+		"label tenant=team-a was added by cluster policy",
+	}
+	if !reflect.DeepEqual(warnings, expectedWarnings) {
+		t.Errorf("ApplyDefaults returned warnings %v, expected %v", warnings, expectedWarnings)
+	}
+}
+
+func TestCheckLabelNSSyntax(t *testing.T) {
+	namespaceLabel := &NamespaceLabel{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "syntax",
+			Namespace: "team-a",
+		},
+		Spec: NamespaceLabelSpec{
+			Labels: map[string]string{
+				"label_dfd/sdsdsd": "a",
+				"app":              strings.Repeat("v", 64),
+				"valid":            "ok",
+			},
+			Annotations: map[string]string{
+				"bad key": "a",
+			},
+		},
+	}
+
+	err := namespaceLabel.CheckLabelNS(nil)
+	if !apierrors.IsInvalid(err) {
+		t.Fatalf("expected an invalid error, got %v", err)
+	}
+
+	causes := err.(apierrors.APIStatus).Status().Details.Causes
+	expectedFields := []string{"spec.labels[app]", "spec.labels[label_dfd/sdsdsd]", "spec.annotations[bad key]"}
+	fields := []string{}
+	for _, cause := range causes {
+		fields = append(fields, cause.Field)
+	}
+	if !reflect.DeepEqual(fields, expectedFields) {
+		t.Errorf("CheckLabelNS reported fields %v, expected %v", fields, expectedFields)
+	}
+}
+
+func TestCheckLabelNSPropagation(t *testing.T) {
+	namespaceLabel := &NamespaceLabel{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "propagation",
+			Namespace: "team-a",
+		},
+		Spec: NamespaceLabelSpec{
+			Labels: map[string]string{"tier": "gold"},
+			Propagation: &Propagation{
+				Keys: []string{"tier", "cost-center"},
+			},
+		},
+	}
+
+	err := namespaceLabel.CheckLabelNS(nil)
+	if !apierrors.IsInvalid(err) {
+		t.Fatalf("expected an invalid error, got %v", err)
+	}
+	causes := err.(apierrors.APIStatus).Status().Details.Causes
+	if len(causes) != 1 || causes[0].Field != "spec.propagation.keys[1]" {
+		t.Errorf("expected only the key missing from the labels to be invalid, got %v", causes)
+	}
+}
+
+func TestCheckLabelNSTemplates(t *testing.T) {
+	namespaceLabel := &NamespaceLabel{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "templates",
+			Namespace: "team-a",
+		},
+		Spec: NamespaceLabelSpec{
+			Labels: map[string]string{
+				"env":     `{{ regexCapture .Namespace.Name "^(team)-" }}`,
+				"broken":  "{{ .Namespace.Name",
+				"invalid": "{{ .Namespace.Name }} and more",
+			},
+		},
+	}
+
+	err := namespaceLabel.CheckLabelNS(nil)
+	if !apierrors.IsInvalid(err) {
+		t.Fatalf("expected an invalid error, got %v", err)
+	}
+
+	causes := err.(apierrors.APIStatus).Status().Details.Causes
+	expectedFields := []string{"spec.labels[broken]", "spec.labels[invalid]"}
+	fields := []string{}
+	for _, cause := range causes {
+		fields = append(fields, cause.Field)
+	}
+	if !reflect.DeepEqual(fields, expectedFields) {
+		t.Errorf("CheckLabelNS reported fields %v, expected %v", fields, expectedFields)
+	}
+}
+
+func TestCheckLabelNSLimits(t *testing.T) {
+	s := runtime.NewScheme()
+	if err := configv1alpha1.AddToScheme(s); err != nil {
+		t.Fatalf("Unable to add to scheme: %v", err)
+	}
+	if err := AddToScheme(s); err != nil {
+		t.Fatalf("Unable to add to scheme: %v", err)
+	}
+	if err := v1.AddToScheme(s); err != nil {
+		t.Fatalf("Unable to add to scheme: %v", err)
+	}
+	config := &configv1alpha1.NamespacelabelConfig{
+		ObjectMeta: metav1.ObjectMeta{Name: configv1alpha1.ClusterConfigName},
+		Spec: configv1alpha1.NamespacelabelConfigSpec{
+			Limits: configv1alpha1.NamespacelabelConfigLimits{MaxLabelsPerObject: pointer.Int32(1)},
+		},
+	}
+	namespacelabelClient = fake.NewClientBuilder().WithScheme(s).WithObjects(config).Build()
+	defer func() { namespacelabelClient = nil }()
+
+	old := &NamespaceLabel{
+		ObjectMeta: metav1.ObjectMeta{Name: "limits", Namespace: "team-a"},
+		Spec:       NamespaceLabelSpec{Labels: map[string]string{"a": "1", "b": "2", "c": "3"}},
+	}
+
+	// the limit was lowered after the NamespaceLabel was created, it may still shrink
+	namespaceLabel := old.DeepCopy()
+	delete(namespaceLabel.Spec.Labels, "c")
+	if err := namespaceLabel.CheckLabelNS(old); err != nil {
+		t.Errorf("expected an update shrinking the labels to be allowed, got %v", err)
+	}
+
+	namespaceLabel.Spec.Labels["b"] = "changed"
+	if err := namespaceLabel.CheckLabelNS(old); err != nil {
+		t.Errorf("expected an update keeping the number of labels to be allowed, got %v", err)
+	}
+
+	namespaceLabel.Spec.Labels["c"] = "3"
+	namespaceLabel.Spec.Labels["d"] = "4"
+	if err := namespaceLabel.CheckLabelNS(old); !apierrors.IsInvalid(err) {
+		t.Errorf("expected an update adding labels to be denied, got %v", err)
+	}
+	if err := old.CheckLabelNS(nil); !apierrors.IsInvalid(err) {
+		t.Errorf("expected a create over the limit to be denied, got %v", err)
+	}
+}
+
+func TestValidateSchedules(t *testing.T) {
+	notBefore := metav1.NewTime(time.Date(2022, 6, 1, 12, 0, 0, 0, time.UTC))
+	expiresAt := metav1.NewTime(notBefore.Add(-time.Hour))
+	namespaceLabel := &NamespaceLabel{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "schedules",
+			Namespace: "team-a",
+		},
+		Spec: NamespaceLabelSpec{
+			Labels:      map[string]string{"maintenance": "true"},
+			Annotations: map[string]string{"notice": "planned"},
+			Schedules: []KeySchedule{
+				{Key: "maintenance", NotBefore: &notBefore},
+				{Key: "notice", NotBefore: &notBefore, ExpiresAt: &expiresAt},
+				{Key: "maintenance"},
+				{Key: "missing"},
+			},
+		},
+	}
+
+	fields := []string{}
+	for _, err := range namespaceLabel.validateSchedules() {
+		fields = append(fields, err.Field)
+	}
+	expectedFields := []string{"spec.schedules[1].expiresAt", "spec.schedules[2].key", "spec.schedules[3].key"}
+	if !reflect.DeepEqual(fields, expectedFields) {
+		t.Errorf("validateSchedules reported fields %v, expected %v", fields, expectedFields)
+	}
+}
+
+func TestValidateKeyDeletionPolicies(t *testing.T) {
+	namespaceLabel := &NamespaceLabel{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "deletion",
+			Namespace: "team-a",
+		},
+		Spec: NamespaceLabelSpec{
+			Labels:      map[string]string{"zone": "internal"},
+			Annotations: map[string]string{"owner": "team-a"},
+			KeyDeletionPolicies: []KeyDeletionPolicy{
+				{Key: "zone", Policy: DeletionPolicyRetain},
+				{Key: "owner", Policy: DeletionPolicyDelete},
+				{Key: "zone", Policy: DeletionPolicyDelete},
+				{Key: "missing", Policy: DeletionPolicyRetain},
+			},
+		},
+	}
+
+	fields := []string{}
+	for _, err := range namespaceLabel.validateKeyDeletionPolicies() {
+		fields = append(fields, err.Field)
+	}
+	expectedFields := []string{"spec.keyDeletionPolicies[2].key", "spec.keyDeletionPolicies[3].key"}
+	if !reflect.DeepEqual(fields, expectedFields) {
+		t.Errorf("validateKeyDeletionPolicies reported fields %v, expected %v", fields, expectedFields)
+	}
+
+	// check that the policy of a key takes precedence over the policy of the object
+	if policy := namespaceLabel.GetDeletionPolicy("owner"); policy != DeletionPolicyDelete {
+		t.Errorf("GetDeletionPolicy returned %s, expected %s", policy, DeletionPolicyDelete)
+	}
+	if policy := namespaceLabel.GetDeletionPolicy("other"); policy != DeletionPolicyRestoreOriginal {
+		t.Errorf("GetDeletionPolicy returned %s, expected %s", policy, DeletionPolicyRestoreOriginal)
+	}
+}
+
+func TestSetChangedBy(t *testing.T) {
+	old := &NamespaceLabel{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "history",
+			Namespace:   "team-a",
+			Annotations: map[string]string{ChangedByAnnotation: "alice"},
+		},
+		Spec: NamespaceLabelSpec{
+			Labels: map[string]string{"team": "a"},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		change   func(*NamespaceLabel)
+		expected string
+	}{
+		{"spec changed", func(r *NamespaceLabel) { r.Spec.Labels["team"] = "b" }, "bob"},
+		{"metadata changed", func(r *NamespaceLabel) { r.Annotations[ChangedByAnnotation] = "bob" }, "alice"},
+	}
+	for _, test := range tests {
+		namespaceLabel := old.DeepCopy()
+		test.change(namespaceLabel)
+		namespaceLabel.setChangedBy(old, "bob")
+		if changedBy := namespaceLabel.Annotations[ChangedByAnnotation]; changedBy != test.expected {
+			t.Errorf("%s: changed-by is %q, expected %q", test.name, changedBy, test.expected)
+		}
+	}
+}
+
+func TestResolveRollback(t *testing.T) {
+	s := runtime.NewScheme()
+	if err := AddToScheme(s); err != nil {
+		t.Fatalf("Unable to add to scheme: %v", err)
+	}
+	old := &NamespaceLabel{
+		ObjectMeta: metav1.ObjectMeta{Name: "history", Namespace: "team-a", UID: "history-uid"},
+		Spec:       NamespaceLabelSpec{Labels: map[string]string{"team": "b"}},
+	}
+	revision := &NamespaceLabelRevision{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "history-1",
+			Namespace: "team-a",
+			Labels:    map[string]string{RevisionOfLabel: "history-uid"},
+		},
+		Spec: NamespaceLabelRevisionSpec{Revision: 1, Labels: map[string]string{"team": "a"}},
+	}
+	if err := controllerutil.SetControllerReference(old, revision, s); err != nil {
+		t.Fatalf("Unable to set owner: %v", err)
+	}
+	cl := fake.NewClientBuilder().WithScheme(s).WithObjects(revision).Build()
+
+	// check that the keys of the revision are restored in the request and the field is cleared
+	rollbackTo := int64(1)
+	namespaceLabel := old.DeepCopy()
+	namespaceLabel.Spec.RollbackTo = &rollbackTo
+	if err := namespaceLabel.resolveRollback(context.TODO(), cl, old); err != nil {
+		t.Fatalf("resolveRollback returned %v", err)
+	}
+	if namespaceLabel.Spec.RollbackTo != nil || !reflect.DeepEqual(namespaceLabel.Spec.Labels, map[string]string{"team": "a"}) {
+		t.Errorf("resolveRollback set rollbackTo %v and labels %v", namespaceLabel.Spec.RollbackTo, namespaceLabel.Spec.Labels)
+	}
+
+	// check that a missing revision and a rollback on create are rejected
+	missing := int64(2)
+	namespaceLabel = old.DeepCopy()
+	namespaceLabel.Spec.RollbackTo = &missing
+	if err := namespaceLabel.resolveRollback(context.TODO(), cl, old); !apierrors.IsInvalid(err) {
+		t.Errorf("expected an invalid error for a missing revision, got %v", err)
+	}
+	namespaceLabel.Spec.RollbackTo = &rollbackTo
+	if err := namespaceLabel.resolveRollback(context.TODO(), cl, nil); !apierrors.IsInvalid(err) {
+		t.Errorf("expected an invalid error for a rollback on create, got %v", err)
+	}
+}
+
+func TestValidateUpdateImmutability(t *testing.T) {
+	old := &NamespaceLabel{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "pinned",
+			Namespace: "team-a",
+		},
+		Spec: NamespaceLabelSpec{
+			Labels: map[string]string{
+				"cost-center": "1234",
+				"app":         "web",
+			},
+			ImmutableKeys: []string{"cost-center"},
+		},
+	}
+
+	// mutable keys may change freely
+	updated := old.DeepCopy()
+	updated.Spec.Labels["app"] = "api"
+	if err := updated.validateUpdate(old, false); err != nil {
+		t.Errorf("expected a change of a mutable key to be allowed: %v", err)
+	}
+
+	// immutable keys can not change, nor be made mutable again
+	updated = old.DeepCopy()
+	updated.Spec.Labels["cost-center"] = "5678"
+	updated.Spec.ImmutableKeys = nil
+	err := updated.validateUpdate(old, false)
+	if !apierrors.IsInvalid(err) || len(err.(apierrors.APIStatus).Status().Details.Causes) != 2 {
+		t.Errorf("expected two violations of the immutable key, got %v", err)
+	}
+
+	// admins may change immutable keys
+	if err := updated.validateUpdate(old, true); err != nil {
+		t.Errorf("expected an admin to change the immutable key: %v", err)
+	}
+}
+
+func TestValidateLocked(t *testing.T) {
+	old := &NamespaceLabel{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "locked",
+			Namespace: "team-a",
+		},
+		Spec: NamespaceLabelSpec{
+			Labels: map[string]string{
+				"app": "web",
+			},
+			Locked: true,
+		},
+	}
+
+	// metadata changes such as finalizers are still allowed
+	updated := old.DeepCopy()
+	updated.Finalizers = []string{"dana.io/namespacelabel-finalizer"}
+	if err := updated.validateUpdate(old, false); err != nil {
+		t.Errorf("expected a metadata change of a locked object to be allowed: %v", err)
+	}
+
+	updated = old.DeepCopy()
+	updated.Spec.Locked = false
+	if err := updated.validateUpdate(old, false); !apierrors.IsInvalid(err) {
+		t.Errorf("expected unlocking by a tenant to be denied, got %v", err)
+	}
+	if err := updated.validateUpdate(old, true); err != nil {
+		t.Errorf("expected unlocking by an admin to be allowed: %v", err)
+	}
+
+	if err := old.validateDelete(false); !apierrors.IsForbidden(err) {
+		t.Errorf("expected the deletion of a locked object to be denied, got %v", err)
+	}
+	if err := old.validateDelete(true); err != nil {
+		t.Errorf("expected the deletion by an admin to be allowed: %v", err)
+	}
+}
+
+func TestCheckKeyPermissions(t *testing.T) {
+	spec := &configv1alpha1.NamespacelabelConfigSpec{
+		KeyPermissions: []configv1alpha1.KeyPermission{
+			{Key: "cost-center/*", Groups: []string{"finops"}},
+		},
+	}
+	tenant := authenticationv1.UserInfo{Username: "bob", Groups: []string{"tenants"}}
+
+	oldLabels := map[string]string{"cost-center/id": "1234"}
+	labels := map[string]string{"cost-center/id": "1234", "app": "web"}
+
+	// restricted keys that are left unchanged do not block edits of other keys
+	if errs := checkKeyPermissions(spec, field.NewPath("spec", "labels"), oldLabels, labels, tenant); len(errs) != 0 {
+		t.Errorf("expected unchanged restricted keys to be allowed, got %v", errs)
+	}
+
+	labels["cost-center/id"] = "5678"
+	errs := checkKeyPermissions(spec, field.NewPath("spec", "labels"), oldLabels, labels, tenant)
+	if len(errs) != 1 || errs[0].Field != "spec.labels[cost-center/id]" {
+		t.Errorf("expected the changed restricted key to be denied, got %v", errs)
+	}
+}
+
+// reviewClient answers SubjectAccessReviews by allowing only the listed keys
+type reviewClient struct {
+	client.Client
+	allowedKeys map[string]bool
+}
+
+func (c *reviewClient) Create(ctx context.Context, obj client.Object, opts ...client.CreateOption) error {
+	if review, ok := obj.(*authorizationv1.SubjectAccessReview); ok {
+		review.Status.Allowed = c.allowedKeys[review.Spec.ResourceAttributes.Verb+" "+review.Spec.ResourceAttributes.Name]
+		return nil
+	}
+
+	return c.Client.Create(ctx, obj, opts...)
+}
+
+func TestAuthorizeKeys(t *testing.T) {
+	s := runtime.NewScheme()
+	if err := configv1alpha1.AddToScheme(s); err != nil {
+		t.Fatalf("Unable to add to scheme: %v", err)
+	}
+	config := &configv1alpha1.NamespacelabelConfig{
+		ObjectMeta: metav1.ObjectMeta{Name: configv1alpha1.ClusterConfigName},
+		Spec:       configv1alpha1.NamespacelabelConfigSpec{AuthorizeKeys: true},
+	}
+	cl := fake.NewClientBuilder().WithScheme(s).WithObjects(config).Build()
+
+	namespacelabelClient = cl
+	defer func() { namespacelabelClient = nil }()
+
+	old := &NamespaceLabel{
+		ObjectMeta: metav1.ObjectMeta{Name: "keys", Namespace: "team-a"},
+		Spec: NamespaceLabelSpec{
+			Labels: map[string]string{"app": "web", "tier": "gold", "owner": "bob"},
+		},
+	}
+	updated := old.DeepCopy()
+	updated.Spec.Labels = map[string]string{"app": "web", "tier": "silver", "env": "dev"}
+
+	reviewer := &reviewClient{Client: cl, allowedKeys: map[string]bool{"update tier": true, "create env": true}}
+	user := authenticationv1.UserInfo{Username: "bob"}
+
+	// removing owner needs the delete verb, which bob was not granted
+	err := updated.authorizeKeys(context.TODO(), reviewer, old, user)
+	if !apierrors.IsInvalid(err) {
+		t.Fatalf("expected an invalid error, got %v", err)
+	}
+	causes := err.(apierrors.APIStatus).Status().Details.Causes
+	if len(causes) != 1 || causes[0].Field != "spec.labels[owner]" {
+		t.Errorf("expected only the removed key to be denied, got %v", causes)
+	}
+
+	reviewer.allowedKeys["delete owner"] = true
+	if err := updated.authorizeKeys(context.TODO(), reviewer, old, user); err != nil {
+		t.Errorf("expected every change to be authorized: %v", err)
+	}
+}
+
+func TestCheckKeyConflicts(t *testing.T) {
+	s := runtime.NewScheme()
+	if err := configv1alpha1.AddToScheme(s); err != nil {
+		t.Fatalf("Unable to add to scheme: %v", err)
+	}
+	if err := AddToScheme(s); err != nil {
+		t.Fatalf("Unable to add to scheme: %v", err)
+	}
+	if err := v1.AddToScheme(s); err != nil {
+		t.Fatalf("Unable to add to scheme: %v", err)
+	}
+
+	config := &configv1alpha1.NamespacelabelConfig{
+		ObjectMeta: metav1.ObjectMeta{Name: configv1alpha1.ClusterConfigName},
+	}
+	namespace := &v1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{Name: "team-a", Labels: map[string]string{"tenant": "true"}},
+	}
+	other := &NamespaceLabel{
+		ObjectMeta: metav1.ObjectMeta{Name: "other", Namespace: "team-a"},
+		Spec: NamespaceLabelSpec{
+			Labels: map[string]string{"app": "api", "env": "dev"},
+		},
+	}
+	clusterNamespaceLabel := &ClusterNamespaceLabel{
+		ObjectMeta: metav1.ObjectMeta{Name: "zones"},
+		Spec: ClusterNamespaceLabelSpec{
+			NamespaceSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"tenant": "true"}},
+			Labels:            map[string]string{"network-zone": "internal"},
+		},
+	}
+	cl := fake.NewClientBuilder().WithScheme(s).WithObjects(config, namespace, other, clusterNamespaceLabel).Build()
+
+	namespacelabelClient = cl
+	defer func() { namespacelabelClient = nil }()
+
+	namespaceLabel := &NamespaceLabel{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "team-a"},
+		Spec: NamespaceLabelSpec{
+			Labels: map[string]string{"app": "web", "env": "dev", "network-zone": "external"},
+		},
+	}
+
+	// colliding keys are warned about by default, keys set to the same value do not collide
+	warnings, err := namespaceLabel.checkKeyConflicts(context.TODO())
+	if err != nil {
+		t.Fatalf("expected conflicts to be allowed: %v", err)
+	}
+	expected := []string{
+		`spec.labels[network-zone]: Forbidden: key is set to "internal" by ClusterNamespaceLabel zones`,
+		`spec.labels[app]: Forbidden: key is set to "api" by NamespaceLabel team-a/other`,
+	}
+	if !reflect.DeepEqual(warnings, expected) {
+		t.Errorf("expected warnings %v, got %v", expected, warnings)
+	}
+
+	// the policy can reject colliding keys instead
+	config.Spec.KeyConflicts = configv1alpha1.KeyConflictReject
+	if err := cl.Update(context.TODO(), config); err != nil {
+		t.Fatalf("update: (%v)", err)
+	}
+	warnings, err = namespaceLabel.checkKeyConflicts(context.TODO())
+	if !apierrors.IsInvalid(err) || len(err.(apierrors.APIStatus).Status().Details.Causes) != 2 {
+		t.Errorf("expected both conflicts to be rejected, got %v", err)
+	}
+	if len(warnings) != 0 {
+		t.Errorf("expected no warnings, got %v", warnings)
+	}
+}
+
+func TestValidateTemplateReferences(t *testing.T) {
+	labels := map[string]string{
+		"static": "{{ .Namespace.Name }}",
+		"owner":  "{{ .Namespace.Labels.team }}",
+		"echo":   "{{ .Namespace.Labels.echo }}",
+		"all":    "{{ range .Namespace.Labels }}{{ . }}{{ end }}",
+	}
+	managedLabels := map[string]string{"echo": "", "static": "prod"}
+
+	fields := []string{}
+	for _, err := range validateTemplateReferences(field.NewPath("spec", "labels"), labels, "Labels", managedLabels) {
+		fields = append(fields, err.Field)
+	}
+	expectedFields := []string{"spec.labels[all]", "spec.labels[echo]"}
+	if !reflect.DeepEqual(fields, expectedFields) {
+		t.Errorf("validateTemplateReferences reported fields %v, expected %v", fields, expectedFields)
+	}
+}
+
+func TestWebhooksAllowLockedDeletion(t *testing.T) {
+	s := runtime.NewScheme()
+	if err := AddToScheme(s); err != nil {
+		t.Fatalf("Unable to add to scheme: %v", err)
+	}
+	decoder, err := admission.NewDecoder(s)
+	if err != nil {
+		t.Fatalf("Unable to create decoder: %v", err)
+	}
+
+	// a locked NamespaceLabel that is being deleted, whose spec no longer matches the defaults
+	now := metav1.Now()
+	old := &NamespaceLabel{
+		TypeMeta: metav1.TypeMeta{APIVersion: GroupVersion.String(), Kind: "NamespaceLabel"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              "locked",
+			Namespace:         "team-a",
+			Finalizers:        []string{"dana.io/namespacelabel-finalizer"},
+			DeletionTimestamp: &now,
+		},
+		Spec: NamespaceLabelSpec{
+			Labels: map[string]string{"app": "web"},
+			Locked: true,
+		},
+	}
+	updated := old.DeepCopy()
+	updated.Finalizers = nil
+
+	oldRaw, err := json.Marshal(old)
+	if err != nil {
+		t.Fatalf("Unable to marshal: %v", err)
+	}
+	raw, err := json.Marshal(updated)
+	if err != nil {
+		t.Fatalf("Unable to marshal: %v", err)
+	}
+	managerUsername := "system:serviceaccount:namespacelabel-system:controller-manager"
+	req := admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+		Operation: admissionv1.Update,
+		Object:    runtime.RawExtension{Raw: raw},
+		OldObject: runtime.RawExtension{Raw: oldRaw},
+		UserInfo:  authenticationv1.UserInfo{Username: managerUsername},
+	}}
+
+	defaulter := &namespaceLabelDefaulter{}
+	if err := defaulter.InjectDecoder(decoder); err != nil {
+		t.Fatalf("Unable to inject decoder: %v", err)
+	}
+	if resp := defaulter.Handle(context.TODO(), req); !resp.Allowed || len(resp.Patches) != 0 {
+		t.Errorf("expected the finalizer removal to be left alone by the defaulter, got %v", resp)
+	}
+
+	validator := &namespaceLabelValidator{ManagerUsername: managerUsername}
+	if err := validator.InjectDecoder(decoder); err != nil {
+		t.Fatalf("Unable to inject decoder: %v", err)
+	}
+	if resp := validator.Handle(context.TODO(), req); !resp.Allowed {
+		t.Errorf("expected the finalizer removal by the manager to be allowed, got %v", resp.Result)
+	}
+}
+
+func TestValidatorAuthorizesDeletedKeys(t *testing.T) {
+	s := runtime.NewScheme()
+	if err := configv1alpha1.AddToScheme(s); err != nil {
+		t.Fatalf("Unable to add to scheme: %v", err)
+	}
+	if err := AddToScheme(s); err != nil {
+		t.Fatalf("Unable to add to scheme: %v", err)
+	}
+	config := &configv1alpha1.NamespacelabelConfig{
+		ObjectMeta: metav1.ObjectMeta{Name: configv1alpha1.ClusterConfigName},
+		Spec:       configv1alpha1.NamespacelabelConfigSpec{AuthorizeKeys: true},
+	}
+	cl := fake.NewClientBuilder().WithScheme(s).WithObjects(config).Build()
+
+	namespacelabelClient = cl
+	defer func() { namespacelabelClient = nil }()
+
+	decoder, err := admission.NewDecoder(s)
+	if err != nil {
+		t.Fatalf("Unable to create decoder: %v", err)
+	}
+	reviewer := &reviewClient{Client: cl, allowedKeys: map[string]bool{"delete app": true}}
+	validator := &namespaceLabelValidator{Client: reviewer}
+	if err := validator.InjectDecoder(decoder); err != nil {
+		t.Fatalf("Unable to inject decoder: %v", err)
+	}
+
+	old := &NamespaceLabel{
+		TypeMeta:   metav1.TypeMeta{APIVersion: GroupVersion.String(), Kind: "NamespaceLabel"},
+		ObjectMeta: metav1.ObjectMeta{Name: "keys", Namespace: "team-a"},
+		Spec: NamespaceLabelSpec{
+			Labels: map[string]string{"app": "web", "owner": "bob"},
+		},
+	}
+	oldRaw, err := json.Marshal(old)
+	if err != nil {
+		t.Fatalf("Unable to marshal: %v", err)
+	}
+	req := admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+		Operation: admissionv1.Delete,
+		OldObject: runtime.RawExtension{Raw: oldRaw},
+		UserInfo:  authenticationv1.UserInfo{Username: "bob"},
+	}}
+
+	// deleting the NamespaceLabel removes owner, which bob may not delete
+	resp := validator.Handle(context.TODO(), req)
+	if resp.Allowed || resp.Result == nil || resp.Result.Details == nil {
+		t.Fatalf("expected the deletion to be denied, got %v", resp.Result)
+	}
+	if causes := resp.Result.Details.Causes; len(causes) != 1 || causes[0].Field != "spec.labels[owner]" {
+		t.Errorf("expected only the unauthorized key to be denied, got %v", causes)
+	}
+
+	reviewer.allowedKeys["delete owner"] = true
+	if resp := validator.Handle(context.TODO(), req); !resp.Allowed {
+		t.Errorf("expected the deletion to be allowed, got %v", resp.Result)
+	}
+}
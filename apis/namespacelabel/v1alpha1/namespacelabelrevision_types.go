@@ -0,0 +1,96 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"context"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// RevisionOfLabel is the label of a NamespaceLabelRevision holding the UID of the NamespaceLabel it
+// belongs to, since the name of a NamespaceLabel may be longer than a label value can be
+const RevisionOfLabel = "dana.io/namespacelabel-uid"
+
+// ChangedByAnnotation is the annotation of a NamespaceLabel naming the user who last changed its spec,
+// it is set by the defaulting webhook and recorded as the author of the next revision
+const ChangedByAnnotation = "dana.io/changed-by"
+
+// NamespaceLabelRevisionSpec holds a label set applied by a NamespaceLabel
+type NamespaceLabelRevisionSpec struct {
+	// Number of the revision, increasing with every label set the NamespaceLabel applies
+	// +kubebuilder:validation:Minimum=1
+	Revision int64 `json:"revision"`
+
+	// Map of labels requested by the NamespaceLabel
+	// +optional
+	Labels map[string]string `json:"labels,omitempty"`
+
+	// Map of annotations requested by the NamespaceLabel
+	// +optional
+	Annotations map[string]string `json:"annotations,omitempty"`
+
+	// User who made the change, taken from the admission request or the managed fields
+	// +optional
+	Author string `json:"author,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:printcolumn:name="NamespaceLabel",type="string",JSONPath=".metadata.ownerReferences[0].name"
+//+kubebuilder:printcolumn:name="Revision",type="integer",JSONPath=".spec.revision"
+//+kubebuilder:printcolumn:name="Author",type="string",JSONPath=".spec.author"
+//+kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+
+// NamespaceLabelRevision is an immutable record of a label set applied by a NamespaceLabel
+type NamespaceLabelRevision struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec NamespaceLabelRevisionSpec `json:"spec,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// NamespaceLabelRevisionList contains a list of NamespaceLabelRevision
+type NamespaceLabelRevisionList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []NamespaceLabelRevision `json:"items"`
+}
+
+// GetRevision returns the revision of the NamespaceLabel with the given number, or nil when there is none.
+// Revisions left behind by a deleted NamespaceLabel of the same name are not returned
+func GetRevision(ctx context.Context, c client.Reader, namespaceLabel *NamespaceLabel, number int64) (*NamespaceLabelRevision, error) {
+	revisions := &NamespaceLabelRevisionList{}
+	if err := c.List(ctx, revisions, client.InNamespace(namespaceLabel.Namespace), client.MatchingLabels{RevisionOfLabel: string(namespaceLabel.UID)}); err != nil {
+		return nil, err
+	}
+
+	for i := range revisions.Items {
+		revision := &revisions.Items[i]
+		if revision.Spec.Revision == number && metav1.IsControlledBy(revision, namespaceLabel) {
+			return revision, nil
+		}
+	}
+
+	return nil, nil
+}
+
+func init() {
+	SchemeBuilder.Register(&NamespaceLabelRevision{}, &NamespaceLabelRevisionList{})
+}
@@ -0,0 +1,63 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+)
+
+// log is for logging in this package.
+var namespacelabelrevisionlog = logf.Log.WithName("namespacelabelrevision-resource")
+
+func (r *NamespaceLabelRevision) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(r).
+		Complete()
+}
+
+//+kubebuilder:webhook:path=/validate-dana-io-dana-io-v1alpha1-namespacelabelrevision,mutating=false,failurePolicy=fail,sideEffects=None,groups=dana.io.dana.io,resources=namespacelabelrevisions,verbs=update,versions=v1alpha1,name=vnamespacelabelrevision.kb.io,admissionReviewVersions=v1
+
+var _ webhook.Validator = &NamespaceLabelRevision{}
+
+// ValidateCreate implements webhook.Validator so a webhook will be registered for the type
+func (r *NamespaceLabelRevision) ValidateCreate() error {
+	return nil
+}
+
+// ValidateUpdate implements webhook.Validator so a webhook will be registered for the type,
+// the spec of a revision is a record of the past and can not be changed
+func (r *NamespaceLabelRevision) ValidateUpdate(old runtime.Object) error {
+	namespacelabelrevisionlog.Info("validate update", "name", r.Name)
+
+	if equality.Semantic.DeepEqual(old.(*NamespaceLabelRevision).Spec, r.Spec) {
+		return nil
+	}
+
+	allErrs := field.ErrorList{field.Forbidden(field.NewPath("spec"), "revision is immutable")}
+	return apierrors.NewInvalid(GroupVersion.WithKind("NamespaceLabelRevision").GroupKind(), r.Name, allErrs)
+}
+
+// ValidateDelete implements webhook.Validator so a webhook will be registered for the type
+func (r *NamespaceLabelRevision) ValidateDelete() error {
+	return nil
+}
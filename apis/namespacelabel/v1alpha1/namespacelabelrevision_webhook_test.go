@@ -0,0 +1,46 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"testing"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestValidateRevisionUpdate(t *testing.T) {
+	old := &NamespaceLabelRevision{
+		ObjectMeta: metav1.ObjectMeta{Name: "labels-1", Namespace: "team-a"},
+		Spec: NamespaceLabelRevisionSpec{
+			Revision: 1,
+			Labels:   map[string]string{"tier": "gold"},
+		},
+	}
+
+	// metadata changes such as the owner references of the garbage collector are allowed
+	revision := old.DeepCopy()
+	revision.Labels = map[string]string{RevisionOfLabel: "labels-uid"}
+	if err := revision.ValidateUpdate(old); err != nil {
+		t.Errorf("expected a metadata change to be allowed, got %v", err)
+	}
+
+	revision.Spec.Labels["tier"] = "silver"
+	if err := revision.ValidateUpdate(old); !apierrors.IsInvalid(err) {
+		t.Errorf("expected a spec change to be denied, got %v", err)
+	}
+}
@@ -21,7 +21,6 @@ import (
 	"crypto/tls"
 	"fmt"
 	"net"
-	"os"
 	"path/filepath"
 	"testing"
 	"time"
@@ -32,6 +31,7 @@ import (
 	admissionv1beta1 "k8s.io/api/admission/v1beta1"
 	//+kubebuilder:scaffold:imports
 
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/client-go/rest"
@@ -41,6 +41,8 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/envtest/printer"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+
+	configv1alpha1 "home-assignment/apis/config/v1alpha1"
 )
 
 // These tests use Ginkgo (BDD-style Go testing framework). Refer to
@@ -84,6 +86,9 @@ var _ = BeforeSuite(func() {
 	err = AddToScheme(scheme)
 	Expect(err).NotTo(HaveOccurred())
 
+	err = configv1alpha1.AddToScheme(scheme)
+	Expect(err).NotTo(HaveOccurred())
+
 	err = admissionv1beta1.AddToScheme(scheme)
 	Expect(err).NotTo(HaveOccurred())
 
@@ -105,7 +110,7 @@ var _ = BeforeSuite(func() {
 	})
 	Expect(err).NotTo(HaveOccurred())
 
-	err = (&NamespaceLabel{}).SetupWebhookWithManager(mgr)
+	err = (&NamespaceLabel{}).SetupWebhookWithManager(mgr, "")
 	Expect(err).NotTo(HaveOccurred())
 
 	//+kubebuilder:scaffold:webhook
@@ -140,9 +145,21 @@ var _ = AfterSuite(func() {
 var _ = Describe("Namespacelabel Controller Webhooks", func() {
 
 	ctx, cancel = context.WithCancel(context.TODO())
-	// set environment variable
-	protectedLabels := "kubernetes.io,openshift.io"
-	os.Setenv("PROTECTED_MANAGEMENT_LABELS_DOMAINS", protectedLabels)
+
+	BeforeEach(func() {
+		// create the cluster policy
+		config := configv1alpha1.NamespacelabelConfig{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: configv1alpha1.ClusterConfigName,
+			},
+			Spec: configv1alpha1.NamespacelabelConfigSpec{
+				ProtectedDomains: []string{"kubernetes.io", "openshift.io"},
+			},
+		}
+		if err := k8sClient.Create(ctx, &config); !apierrors.IsAlreadyExists(err) {
+			Expect(err).NotTo(HaveOccurred())
+		}
+	})
 
 	Context("When updating NamespaceLabel Status", func() {
 		It("Should allow creation,  updating and deletion of NamespaceLabel objects", func() {
@@ -179,8 +196,8 @@ var _ = Describe("Namespacelabel Controller Webhooks", func() {
 
 		})
 
-		It("Should deny creation of NamespaceLabel objects", func() {
-			By("Checking if a NamespaceLabel name is different from the namespace")
+		It("Should allow more than one NamespaceLabel object per namespace", func() {
+			By("Creating a NamespaceLabel whose name is different from the namespace")
 
 			// create a NamespaceLabel object
 			namespaceLabel := NamespaceLabel{
@@ -198,7 +215,10 @@ var _ = Describe("Namespacelabel Controller Webhooks", func() {
 					},
 				},
 			}
-			Expect(k8sClient.Create(ctx, &namespaceLabel)).ShouldNot(Succeed())
+			Expect(k8sClient.Create(ctx, &namespaceLabel)).Should(Succeed())
+
+			By("Deletion of object")
+			Expect(k8sClient.Delete(ctx, &namespaceLabel)).Should(Succeed())
 		})
 
 		It("Should not allow certain labels to be set", func() {
@@ -221,5 +241,32 @@ var _ = Describe("Namespacelabel Controller Webhooks", func() {
 			}
 			Expect(k8sClient.Create(ctx, &namespaceLabelThree)).ShouldNot(Succeed())
 		})
+
+		It("Should report every denied label at once", func() {
+			By("Checking that each protected label gets its own cause")
+			namespaceLabelFour := NamespaceLabel{
+				TypeMeta: metav1.TypeMeta{
+					APIVersion: "dana.io.dana.io/v1alpha1",
+					Kind:       "NamespaceLabel",
+				},
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "denied",
+					Namespace: "default",
+				},
+				Spec: NamespaceLabelSpec{
+					Labels: map[string]string{
+						"kubernetes.io/metadata.name": "testName",
+						"openshift.io/run-level":      "0",
+						"notkubernetes.io/name":       "allowed",
+					},
+				},
+			}
+			err := k8sClient.Create(ctx, &namespaceLabelFour)
+			Expect(apierrors.IsInvalid(err)).To(BeTrue())
+
+			statusErr, ok := err.(apierrors.APIStatus)
+			Expect(ok).To(BeTrue())
+			Expect(statusErr.Status().Details.Causes).To(HaveLen(2))
+		})
 	})
 })
@@ -22,9 +22,240 @@ limitations under the License.
 package v1alpha1
 
 import (
+	"k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ClusterNamespaceLabel) DeepCopyInto(out *ClusterNamespaceLabel) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterNamespaceLabel.
+func (in *ClusterNamespaceLabel) DeepCopy() *ClusterNamespaceLabel {
+	if in == nil {
+		return nil
+	}
+	out := new(ClusterNamespaceLabel)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ClusterNamespaceLabel) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ClusterNamespaceLabelList) DeepCopyInto(out *ClusterNamespaceLabelList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]ClusterNamespaceLabel, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterNamespaceLabelList.
+func (in *ClusterNamespaceLabelList) DeepCopy() *ClusterNamespaceLabelList {
+	if in == nil {
+		return nil
+	}
+	out := new(ClusterNamespaceLabelList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ClusterNamespaceLabelList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ClusterNamespaceLabelSpec) DeepCopyInto(out *ClusterNamespaceLabelSpec) {
+	*out = *in
+	if in.Labels != nil {
+		in, out := &in.Labels, &out.Labels
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Annotations != nil {
+		in, out := &in.Annotations, &out.Annotations
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.NamespaceSelector != nil {
+		in, out := &in.NamespaceSelector, &out.NamespaceSelector
+		*out = new(v1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.NamespaceNamePatterns != nil {
+		in, out := &in.NamespaceNamePatterns, &out.NamespaceNamePatterns
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterNamespaceLabelSpec.
+func (in *ClusterNamespaceLabelSpec) DeepCopy() *ClusterNamespaceLabelSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ClusterNamespaceLabelSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ClusterNamespaceLabelStatus) DeepCopyInto(out *ClusterNamespaceLabelStatus) {
+	*out = *in
+	if in.MatchedNamespaces != nil {
+		in, out := &in.MatchedNamespaces, &out.MatchedNamespaces
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Targets != nil {
+		in, out := &in.Targets, &out.Targets
+		*out = make([]ClusterNamespaceLabelTarget, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterNamespaceLabelStatus.
+func (in *ClusterNamespaceLabelStatus) DeepCopy() *ClusterNamespaceLabelStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(ClusterNamespaceLabelStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ClusterNamespaceLabelTarget) DeepCopyInto(out *ClusterNamespaceLabelTarget) {
+	*out = *in
+	if in.ActiveLabels != nil {
+		in, out := &in.ActiveLabels, &out.ActiveLabels
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.ActiveAnnotations != nil {
+		in, out := &in.ActiveAnnotations, &out.ActiveAnnotations
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterNamespaceLabelTarget.
+func (in *ClusterNamespaceLabelTarget) DeepCopy() *ClusterNamespaceLabelTarget {
+	if in == nil {
+		return nil
+	}
+	out := new(ClusterNamespaceLabelTarget)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KeyDeletionPolicy) DeepCopyInto(out *KeyDeletionPolicy) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KeyDeletionPolicy.
+func (in *KeyDeletionPolicy) DeepCopy() *KeyDeletionPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(KeyDeletionPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KeyResult) DeepCopyInto(out *KeyResult) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KeyResult.
+func (in *KeyResult) DeepCopy() *KeyResult {
+	if in == nil {
+		return nil
+	}
+	out := new(KeyResult)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KeySchedule) DeepCopyInto(out *KeySchedule) {
+	*out = *in
+	if in.NotBefore != nil {
+		in, out := &in.NotBefore, &out.NotBefore
+		*out = (*in).DeepCopy()
+	}
+	if in.ExpiresAt != nil {
+		in, out := &in.ExpiresAt, &out.ExpiresAt
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KeySchedule.
+func (in *KeySchedule) DeepCopy() *KeySchedule {
+	if in == nil {
+		return nil
+	}
+	out := new(KeySchedule)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KeyTransition) DeepCopyInto(out *KeyTransition) {
+	*out = *in
+	in.Time.DeepCopyInto(&out.Time)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KeyTransition.
+func (in *KeyTransition) DeepCopy() *KeyTransition {
+	if in == nil {
+		return nil
+	}
+	out := new(KeyTransition)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *NamespaceLabel) DeepCopyInto(out *NamespaceLabel) {
 	*out = *in
@@ -84,6 +315,93 @@ func (in *NamespaceLabelList) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NamespaceLabelRevision) DeepCopyInto(out *NamespaceLabelRevision) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NamespaceLabelRevision.
+func (in *NamespaceLabelRevision) DeepCopy() *NamespaceLabelRevision {
+	if in == nil {
+		return nil
+	}
+	out := new(NamespaceLabelRevision)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *NamespaceLabelRevision) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NamespaceLabelRevisionList) DeepCopyInto(out *NamespaceLabelRevisionList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]NamespaceLabelRevision, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NamespaceLabelRevisionList.
+func (in *NamespaceLabelRevisionList) DeepCopy() *NamespaceLabelRevisionList {
+	if in == nil {
+		return nil
+	}
+	out := new(NamespaceLabelRevisionList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *NamespaceLabelRevisionList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NamespaceLabelRevisionSpec) DeepCopyInto(out *NamespaceLabelRevisionSpec) {
+	*out = *in
+	if in.Labels != nil {
+		in, out := &in.Labels, &out.Labels
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Annotations != nil {
+		in, out := &in.Annotations, &out.Annotations
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NamespaceLabelRevisionSpec.
+func (in *NamespaceLabelRevisionSpec) DeepCopy() *NamespaceLabelRevisionSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(NamespaceLabelRevisionSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *NamespaceLabelSpec) DeepCopyInto(out *NamespaceLabelSpec) {
 	*out = *in
@@ -94,6 +412,40 @@ func (in *NamespaceLabelSpec) DeepCopyInto(out *NamespaceLabelSpec) {
 			(*out)[key] = val
 		}
 	}
+	if in.Annotations != nil {
+		in, out := &in.Annotations, &out.Annotations
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.ImmutableKeys != nil {
+		in, out := &in.ImmutableKeys, &out.ImmutableKeys
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Propagation != nil {
+		in, out := &in.Propagation, &out.Propagation
+		*out = new(Propagation)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Schedules != nil {
+		in, out := &in.Schedules, &out.Schedules
+		*out = make([]KeySchedule, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.KeyDeletionPolicies != nil {
+		in, out := &in.KeyDeletionPolicies, &out.KeyDeletionPolicies
+		*out = make([]KeyDeletionPolicy, len(*in))
+		copy(*out, *in)
+	}
+	if in.RollbackTo != nil {
+		in, out := &in.RollbackTo, &out.RollbackTo
+		*out = new(int64)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NamespaceLabelSpec.
@@ -116,6 +468,55 @@ func (in *NamespaceLabelStatus) DeepCopyInto(out *NamespaceLabelStatus) {
 			(*out)[key] = val
 		}
 	}
+	if in.ActiveAnnotations != nil {
+		in, out := &in.ActiveAnnotations, &out.ActiveAnnotations
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.LastSyncTime != nil {
+		in, out := &in.LastSyncTime, &out.LastSyncTime
+		*out = (*in).DeepCopy()
+	}
+	if in.KeyResults != nil {
+		in, out := &in.KeyResults, &out.KeyResults
+		*out = make([]KeyResult, len(*in))
+		copy(*out, *in)
+	}
+	if in.OriginalLabels != nil {
+		in, out := &in.OriginalLabels, &out.OriginalLabels
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.OriginalAnnotations != nil {
+		in, out := &in.OriginalAnnotations, &out.OriginalAnnotations
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.UpcomingTransitions != nil {
+		in, out := &in.UpcomingTransitions, &out.UpcomingTransitions
+		*out = make([]KeyTransition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.PlannedChanges != nil {
+		in, out := &in.PlannedChanges, &out.PlannedChanges
+		*out = new(PlannedChanges)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NamespaceLabelStatus.
@@ -127,3 +528,66 @@ func (in *NamespaceLabelStatus) DeepCopy() *NamespaceLabelStatus {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PlannedChanges) DeepCopyInto(out *PlannedChanges) {
+	*out = *in
+	if in.AddLabels != nil {
+		in, out := &in.AddLabels, &out.AddLabels
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.DeleteLabels != nil {
+		in, out := &in.DeleteLabels, &out.DeleteLabels
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.AddAnnotations != nil {
+		in, out := &in.AddAnnotations, &out.AddAnnotations
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.DeleteAnnotations != nil {
+		in, out := &in.DeleteAnnotations, &out.DeleteAnnotations
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PlannedChanges.
+func (in *PlannedChanges) DeepCopy() *PlannedChanges {
+	if in == nil {
+		return nil
+	}
+	out := new(PlannedChanges)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Propagation) DeepCopyInto(out *Propagation) {
+	*out = *in
+	if in.Keys != nil {
+		in, out := &in.Keys, &out.Keys
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Propagation.
+func (in *Propagation) DeepCopy() *Propagation {
+	if in == nil {
+		return nil
+	}
+	out := new(Propagation)
+	in.DeepCopyInto(out)
+	return out
+}
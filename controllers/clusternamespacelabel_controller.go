@@ -0,0 +1,358 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	danaiov1alpha1 "home-assignment/apis/namespacelabel/v1alpha1"
+)
+
+const ClusterNamespaceLabelFinalizer = "dana.io/clusternamespacelabel-finalizer"
+
+// ClusterNamespaceLabelReconciler reconciles a ClusterNamespaceLabel object
+type ClusterNamespaceLabelReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+}
+
+//+kubebuilder:rbac:groups=dana.io.dana.io,resources=clusternamespacelabels,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=dana.io.dana.io,resources=clusternamespacelabels/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=dana.io.dana.io,resources=clusternamespacelabels/finalizers,verbs=update
+//+kubebuilder:rbac:groups=*,resources=namespaces,verbs=get;list;watch;update;patch
+
+// Reconcile applies the keys of a ClusterNamespaceLabel to every namespace it selects
+// and removes them from namespaces it no longer selects
+func (r *ClusterNamespaceLabelReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+	log.Info("Processing ClusterNamespaceLabelReconciler")
+
+	// fetch the ClusterNamespaceLabel using our client
+	var clusterNamespaceLabel danaiov1alpha1.ClusterNamespaceLabel
+	if err := r.Get(ctx, req.NamespacedName, &clusterNamespaceLabel); err != nil {
+		if errors.IsNotFound(err) {
+			log.Info("ClusterNamespaceLabel resource not found. Ignoring since object must be deleted")
+			return ctrl.Result{}, nil
+		}
+
+		log.Error(err, "unable to fetch clusterNamespaceLabel")
+		return ctrl.Result{}, err
+	}
+
+	// examine DeletionTimestamp to determine if object is under deletion
+	if !clusterNamespaceLabel.ObjectMeta.DeletionTimestamp.IsZero() {
+		if err := r.deleteFinalizer(ctx, &clusterNamespaceLabel); err != nil {
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{}, nil
+	}
+
+	if !controllerutil.ContainsFinalizer(&clusterNamespaceLabel, ClusterNamespaceLabelFinalizer) {
+		controllerutil.AddFinalizer(&clusterNamespaceLabel, ClusterNamespaceLabelFinalizer)
+		if err := r.Update(ctx, &clusterNamespaceLabel); err != nil {
+			log.Error(err, "failed to update clusterNamespaceLabel")
+			return ctrl.Result{}, err
+		}
+	}
+
+	// list the namespaces and the other ClusterNamespaceLabels that may select them
+	namespaces := &v1.NamespaceList{}
+	if err := r.List(ctx, namespaces); err != nil {
+		log.Error(err, "unable to list namespaces")
+		return ctrl.Result{}, err
+	}
+
+	clusterNamespaceLabels := &danaiov1alpha1.ClusterNamespaceLabelList{}
+	if err := r.List(ctx, clusterNamespaceLabels); err != nil {
+		log.Error(err, "unable to list clusterNamespaceLabels")
+		return ctrl.Result{}, err
+	}
+
+	origStatus := clusterNamespaceLabel.Status.DeepCopy()
+	activeTargets := make(map[string]danaiov1alpha1.ClusterNamespaceLabelTarget)
+	for _, target := range clusterNamespaceLabel.Status.Targets {
+		activeTargets[target.Name] = target
+	}
+
+	matchedNamespaces := []string{}
+	targets := []danaiov1alpha1.ClusterNamespaceLabelTarget{}
+	var syncErr error
+
+	for i := range namespaces.Items {
+		namespace := &namespaces.Items[i]
+
+		matches, err := clusterNamespaceLabel.MatchesNamespace(namespace.Name, namespace.Labels)
+		if err != nil {
+			log.Error(err, "invalid namespace selector")
+			syncErr = err
+			break
+		}
+		if !matches || namespace.Status.Phase == v1.NamespaceTerminating {
+			continue
+		}
+		matchedNamespaces = append(matchedNamespaces, namespace.Name)
+
+		target, err := r.syncNamespace(ctx, &clusterNamespaceLabel, clusterNamespaceLabels.Items, namespace, activeTargets[namespace.Name])
+		if err != nil {
+			syncErr = err
+			target = activeTargets[namespace.Name]
+			target.Name = namespace.Name
+		}
+		targets = append(targets, target)
+		delete(activeTargets, namespace.Name)
+	}
+
+	// remove the keys from namespaces that are no longer selected
+	for _, target := range activeTargets {
+		if syncErr != nil {
+			targets = append(targets, target)
+			continue
+		}
+		if err := r.releaseNamespace(ctx, &clusterNamespaceLabel, target); err != nil {
+			syncErr = err
+			targets = append(targets, target)
+		}
+	}
+
+	sort.Slice(targets, func(i, j int) bool { return targets[i].Name < targets[j].Name })
+	clusterNamespaceLabel.Status.MatchedNamespaces = matchedNamespaces
+	clusterNamespaceLabel.Status.Targets = targets
+	r.setStatusConditions(&clusterNamespaceLabel, syncErr)
+
+	if !equality.Semantic.DeepEqual(origStatus, &clusterNamespaceLabel.Status) {
+		if err := r.Status().Update(ctx, &clusterNamespaceLabel); err != nil {
+			log.Error(err, "unable to update clusterNamespaceLabel status")
+			return ctrl.Result{}, err
+		}
+	}
+
+	return ctrl.Result{}, syncErr
+}
+
+// syncNamespace applies the keys owned by the ClusterNamespaceLabel to a single namespace
+// and returns the keys that are now active on it
+func (r *ClusterNamespaceLabelReconciler) syncNamespace(ctx context.Context, clusterNamespaceLabel *danaiov1alpha1.ClusterNamespaceLabel, others []danaiov1alpha1.ClusterNamespaceLabel, namespace *v1.Namespace, active danaiov1alpha1.ClusterNamespaceLabelTarget) (danaiov1alpha1.ClusterNamespaceLabelTarget, error) {
+	reqLabels := r.getOwnedKeys(clusterNamespaceLabel, others, namespace, clusterNamespaceLabel.Spec.Labels, clusterSpecLabels)
+	reqAnnotations := r.getOwnedKeys(clusterNamespaceLabel, others, namespace, clusterNamespaceLabel.Spec.Annotations, clusterSpecAnnotations)
+
+	addLabels, delLabels := getNamespaceLabelsDiffs(reqLabels, active.ActiveLabels)
+	addAnnotations, delAnnotations := getNamespaceLabelsDiffs(reqAnnotations, active.ActiveAnnotations)
+
+	// restore active keys that were changed or removed on the namespace out-of-band
+	for key, val := range getNamespaceLabelsDrift(reqLabels, active.ActiveLabels, namespace.Labels) {
+		addLabels[key] = val
+	}
+	for key, val := range getNamespaceLabelsDrift(reqAnnotations, active.ActiveAnnotations, namespace.Annotations) {
+		addAnnotations[key] = val
+	}
+
+	target := danaiov1alpha1.ClusterNamespaceLabelTarget{
+		Name:              namespace.Name,
+		ActiveLabels:      reqLabels,
+		ActiveAnnotations: reqAnnotations,
+	}
+
+	if len(addLabels)+len(delLabels)+len(addAnnotations)+len(delAnnotations) == 0 {
+		return target, nil
+	}
+
+	// keys owned by another ClusterNamespaceLabel are released without being deleted
+	if err := applyNSLabels(ctx, r.Client, clusterNamespaceLabelFieldManager(clusterNamespaceLabel), namespace, reqLabels, reqAnnotations); err != nil {
+		return target, err
+	}
+
+	return target, nil
+}
+
+// releaseNamespace removes the keys a ClusterNamespaceLabel manages from a namespace
+// it no longer selects
+func (r *ClusterNamespaceLabelReconciler) releaseNamespace(ctx context.Context, clusterNamespaceLabel *danaiov1alpha1.ClusterNamespaceLabel, target danaiov1alpha1.ClusterNamespaceLabelTarget) error {
+	namespace := &v1.Namespace{}
+	if err := r.Get(ctx, types.NamespacedName{Name: target.Name}, namespace); err != nil {
+		// nothing to clean up if the namespace is gone
+		return client.IgnoreNotFound(err)
+	}
+
+	if namespace.Status.Phase == v1.NamespaceTerminating {
+		return nil
+	}
+
+	return applyNSLabels(ctx, r.Client, clusterNamespaceLabelFieldManager(clusterNamespaceLabel), namespace, nil, nil)
+}
+
+// clusterNamespaceLabelFieldManager returns the field manager used to apply the keys of a
+// ClusterNamespaceLabel, so the owner of every key is visible in the managedFields of the namespace
+func clusterNamespaceLabelFieldManager(clusterNamespaceLabel *danaiov1alpha1.ClusterNamespaceLabel) string {
+	fieldManager := fmt.Sprintf("clusternamespacelabel/%s", clusterNamespaceLabel.Name)
+	if len(fieldManager) > maxFieldManagerLength {
+		return fmt.Sprintf("clusternamespacelabel/%s", clusterNamespaceLabel.UID)
+	}
+
+	return fieldManager
+}
+
+// this function returns the requested keys of a ClusterNamespaceLabel that are not
+// owned on the namespace by another ClusterNamespaceLabel with precedence over it
+func (r *ClusterNamespaceLabelReconciler) getOwnedKeys(clusterNamespaceLabel *danaiov1alpha1.ClusterNamespaceLabel, others []danaiov1alpha1.ClusterNamespaceLabel, namespace *v1.Namespace, reqKeys map[string]string, keysOf func(*danaiov1alpha1.ClusterNamespaceLabel) map[string]string) map[string]string {
+	ownedKeys := make(map[string]string)
+
+	for key, val := range reqKeys {
+		owned := true
+		for i := range others {
+			other := &others[i]
+			if other.UID == clusterNamespaceLabel.UID || !other.DeletionTimestamp.IsZero() {
+				continue
+			}
+			if _, ok := keysOf(other)[key]; !ok || !hasPrecedence(other.Spec.Priority, other, clusterNamespaceLabel.Spec.Priority, clusterNamespaceLabel) {
+				continue
+			}
+			if matches, err := other.MatchesNamespace(namespace.Name, namespace.Labels); err == nil && matches {
+				owned = false
+				break
+			}
+		}
+
+		if owned {
+			ownedKeys[key] = val
+		}
+	}
+
+	return ownedKeys
+}
+
+func (r *ClusterNamespaceLabelReconciler) deleteFinalizer(ctx context.Context, clusterNamespaceLabel *danaiov1alpha1.ClusterNamespaceLabel) error {
+	log := log.FromContext(ctx)
+	log.Info("Handling finalizer deletion")
+
+	if controllerutil.ContainsFinalizer(clusterNamespaceLabel, ClusterNamespaceLabelFinalizer) {
+		// our finalizer is present, so remove the keys from every namespace
+		for _, target := range clusterNamespaceLabel.Status.Targets {
+			if err := r.releaseNamespace(ctx, clusterNamespaceLabel, target); err != nil {
+				log.Error(err, "failed to update namespace", "namespace", target.Name)
+				return err
+			}
+		}
+
+		// remove our finalizer from the list and update it
+		controllerutil.RemoveFinalizer(clusterNamespaceLabel, ClusterNamespaceLabelFinalizer)
+		if err := r.Update(ctx, clusterNamespaceLabel); err != nil {
+			log.Error(err, "failed to update clusterNamespaceLabel")
+			return err
+		}
+	}
+	return nil
+}
+
+// setStatusConditions records the result of syncing the selected namespaces
+func (r *ClusterNamespaceLabelReconciler) setStatusConditions(clusterNamespaceLabel *danaiov1alpha1.ClusterNamespaceLabel, syncErr error) {
+	status := &clusterNamespaceLabel.Status
+	status.ObservedGeneration = clusterNamespaceLabel.Generation
+
+	synced := metav1.Condition{
+		Type:               danaiov1alpha1.ConditionSynced,
+		Status:             metav1.ConditionTrue,
+		Reason:             ReasonSynced,
+		Message:            fmt.Sprintf("%d selected namespaces are in sync", len(status.MatchedNamespaces)),
+		ObservedGeneration: clusterNamespaceLabel.Generation,
+	}
+	if syncErr != nil {
+		synced.Status = metav1.ConditionFalse
+		synced.Reason = ReasonSyncFailed
+		synced.Message = syncErr.Error()
+	}
+	meta.SetStatusCondition(&status.Conditions, synced)
+
+	ready := synced
+	ready.Type = danaiov1alpha1.ConditionReady
+	if syncErr == nil {
+		ready.Reason = ReasonReady
+	}
+	meta.SetStatusCondition(&status.Conditions, ready)
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *ClusterNamespaceLabelReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&danaiov1alpha1.ClusterNamespaceLabel{}).
+		Watches(&source.Kind{Type: &v1.Namespace{}}, handler.EnqueueRequestsFromMapFunc(r.findClusterNamespaceLabels)).
+		Complete(r)
+}
+
+// findClusterNamespaceLabels maps a namespace event to reconcile requests for every
+// ClusterNamespaceLabel, so that namespaces are picked up as they are created,
+// relabeled or deleted
+func (r *ClusterNamespaceLabelReconciler) findClusterNamespaceLabels(namespace client.Object) []reconcile.Request {
+	clusterNamespaceLabels := &danaiov1alpha1.ClusterNamespaceLabelList{}
+	if err := r.List(context.TODO(), clusterNamespaceLabels); err != nil {
+		return []reconcile.Request{}
+	}
+
+	requests := make([]reconcile.Request, len(clusterNamespaceLabels.Items))
+	for i, item := range clusterNamespaceLabels.Items {
+		requests[i] = reconcile.Request{
+			NamespacedName: types.NamespacedName{
+				Name: item.GetName(),
+			},
+		}
+	}
+
+	return requests
+}
+
+// getClusterNamespaceLabelNamespaces returns the names of the namespaces the ClusterNamespaceLabel
+// selects, together with the namespaces it has labeled and may no longer select
+func getClusterNamespaceLabelNamespaces(ctx context.Context, c client.Reader, clusterNamespaceLabel *danaiov1alpha1.ClusterNamespaceLabel) ([]string, error) {
+	namespaces := &v1.NamespaceList{}
+	if err := c.List(ctx, namespaces); err != nil {
+		return nil, err
+	}
+
+	names := []string{}
+	seen := make(map[string]bool)
+	for _, item := range namespaces.Items {
+		if matched, err := clusterNamespaceLabel.MatchesNamespace(item.Name, item.Labels); err != nil || !matched {
+			continue
+		}
+		seen[item.Name] = true
+		names = append(names, item.Name)
+	}
+	for _, target := range clusterNamespaceLabel.Status.Targets {
+		if !seen[target.Name] {
+			seen[target.Name] = true
+			names = append(names, target.Name)
+		}
+	}
+
+	return names, nil
+}
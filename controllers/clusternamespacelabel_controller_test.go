@@ -0,0 +1,153 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	danaiov1alpha1 "home-assignment/apis/namespacelabel/v1alpha1"
+)
+
+func generateClusterNamespaceLabelObject() *danaiov1alpha1.ClusterNamespaceLabel {
+	clusterNamespaceLabel := &danaiov1alpha1.ClusterNamespaceLabel{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "clusternamespacelabel-test",
+		},
+		Spec: danaiov1alpha1.ClusterNamespaceLabelSpec{
+			NamespaceSelector: &metav1.LabelSelector{
+				MatchLabels: map[string]string{
+					"tenant": "true",
+				},
+			},
+			NamespaceNamePatterns: []string{"team-*"},
+			Labels: map[string]string{
+				"network-zone": "internal",
+			},
+		},
+	}
+
+	return clusterNamespaceLabel
+}
+
+func generateTenantNamespaceObject(name string, labels map[string]string) *v1.Namespace {
+	return &v1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   name,
+			Labels: labels,
+		},
+	}
+}
+
+func TestClusterNamespaceLabelReconciler(t *testing.T) {
+	g := NewGomegaWithT(t)
+	RegisterFailHandler(ginkgo.Fail)
+
+	clusterNamespaceLabel := generateClusterNamespaceLabelObject()
+
+	// team-c was selected before, but its tenant label was removed since
+	clusterNamespaceLabel.Status.Targets = []danaiov1alpha1.ClusterNamespaceLabelTarget{
+		{
+			Name: "team-c",
+			ActiveLabels: map[string]string{
+				"network-zone": "internal",
+			},
+		},
+	}
+
+	obj := []client.Object{
+		clusterNamespaceLabel,
+		generateTenantNamespaceObject("team-a", map[string]string{"tenant": "true"}),
+		generateTenantNamespaceObject("team-b", map[string]string{"tenant": "false"}),
+		generateTenantNamespaceObject("team-c", map[string]string{"network-zone": "internal"}),
+		generateTenantNamespaceObject("other", map[string]string{"tenant": "true"}),
+	}
+
+	cl, s, err := setupClient(obj)
+	if err != nil {
+		t.Fatalf("Unable to add to scheme: %v", err)
+	}
+
+	// create a ClusterNamespaceLabelReconciler object with the scheme and fake client
+	r := &ClusterNamespaceLabelReconciler{cl, s}
+
+	// the label of team-c was applied by the clusternamespacelabel before
+	seedAppliedKeys(cl, clusterNamespaceLabelFieldManager(clusterNamespaceLabel), "team-c", clusterNamespaceLabel.Status.Targets[0].ActiveLabels, nil)
+
+	req := reconcile.Request{
+		NamespacedName: types.NamespacedName{
+			Name: clusterNamespaceLabel.ObjectMeta.Name,
+		},
+	}
+
+	if _, err := r.Reconcile(context.TODO(), req); err != nil {
+		t.Fatalf("Unable to reconcile: %v", err)
+	}
+
+	// check that only the selected namespace got the label
+	expectedLabels := map[string]map[string]string{
+		"team-a": {"tenant": "true", "network-zone": "internal"},
+		"team-b": {"tenant": "false"},
+		"team-c": nil,
+		"other":  {"tenant": "true"},
+	}
+	for name, labels := range expectedLabels {
+		namespace := &v1.Namespace{}
+		if err := r.Get(context.TODO(), types.NamespacedName{Name: name}, namespace); err != nil {
+			t.Fatalf("get: (%v)", err)
+		}
+		g.Expect(namespace.Labels).To(BeEquivalentTo(labels), "namespace %s", name)
+	}
+
+	// check that the status lists the selected namespaces
+	if err := r.Get(context.TODO(), req.NamespacedName, clusterNamespaceLabel); err != nil {
+		t.Fatalf("get: (%v)", err)
+	}
+	g.Expect(clusterNamespaceLabel.Status.MatchedNamespaces).To(Equal([]string{"team-a"}))
+	g.Expect(clusterNamespaceLabel.Status.Targets).To(HaveLen(1))
+	g.Expect(meta.IsStatusConditionTrue(clusterNamespaceLabel.Status.Conditions, danaiov1alpha1.ConditionReady)).To(BeTrue())
+}
+
+func TestGetClusterOwnedKeys(t *testing.T) {
+	g := NewGomegaWithT(t)
+	RegisterFailHandler(ginkgo.Fail)
+
+	clusterNamespaceLabel := generateClusterNamespaceLabelObject()
+	namespace := generateTenantNamespaceObject("team-a", map[string]string{"tenant": "true"})
+
+	reqLabels := map[string]string{
+		"network-zone": "external",
+		LabelKey:       LabelVal,
+	}
+
+	// run function to test
+	ownedKeys := getClusterOwnedKeys(namespace, []danaiov1alpha1.ClusterNamespaceLabel{*clusterNamespaceLabel}, reqLabels, clusterSpecLabels)
+
+	// set expected result and check result matches expected
+	g.Expect(ownedKeys).To(Equal(map[string]string{
+		"network-zone": "key is owned by ClusterNamespaceLabel clusternamespacelabel-test",
+	}))
+}
@@ -0,0 +1,294 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	configv1alpha1 "home-assignment/apis/config/v1alpha1"
+	danaiov1alpha1 "home-assignment/apis/namespacelabel/v1alpha1"
+)
+
+// inheritanceFieldManager is the field manager of the labels a namespace inherits from its ancestors
+const inheritanceFieldManager = "namespacelabel-inheritance"
+
+// InheritanceReconciler applies the labels propagated by the NamespaceLabels of the ancestors
+// of a namespace, the ancestors are found by following the parent annotation of the policy
+type InheritanceReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+}
+
+// inheritedLabel is a label a namespace inherits from a NamespaceLabel of an ancestor
+type inheritedLabel struct {
+	value          string
+	source         string
+	allowOverrides bool
+}
+
+//+kubebuilder:rbac:groups=*,resources=namespaces,verbs=get;list;watch;update;patch
+//+kubebuilder:rbac:groups=dana.io.dana.io,resources=namespacelabels,verbs=get;list;watch
+//+kubebuilder:rbac:groups=dana.io.dana.io,resources=clusternamespacelabels,verbs=get;list;watch
+//+kubebuilder:rbac:groups=config.dana.io,resources=namespacelabelconfigs,verbs=get;list;watch
+
+// Reconcile applies the labels a namespace inherits, leaving out the labels overridden by a
+// NamespaceLabel of the namespace and the labels owned by a ClusterNamespaceLabel
+func (r *InheritanceReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+	log.Info("Processing InheritanceReconciler")
+
+	namespace := &v1.Namespace{}
+	if err := r.Get(ctx, types.NamespacedName{Name: req.Name}, namespace); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	// a terminating namespace takes its labels with it
+	if !namespace.DeletionTimestamp.IsZero() {
+		return ctrl.Result{}, nil
+	}
+
+	config, err := configv1alpha1.GetClusterConfig(ctx, r.Client)
+	if err != nil {
+		log.Error(err, "unable to fetch namespacelabelconfig")
+		return ctrl.Result{}, err
+	}
+
+	inherited, err := getInheritedLabels(ctx, r.Client, config.Spec.ParentAnnotationKey(), namespace)
+	if err != nil {
+		log.Error(err, "unable to get inherited labels")
+		return ctrl.Result{}, err
+	}
+
+	// a NamespaceLabel of the namespace overrides the labels whose propagation allows it
+	namespaceLabels := &danaiov1alpha1.NamespaceLabelList{}
+	if err := r.List(ctx, namespaceLabels, client.InNamespace(namespace.Name)); err != nil {
+		log.Error(err, "unable to list namespaceLabels")
+		return ctrl.Result{}, err
+	}
+	labels := make(map[string]string)
+	for key, label := range inherited {
+		if label.allowOverrides && isRequestedKey(key, namespaceLabels.Items) {
+			continue
+		}
+		labels[key] = label.value
+	}
+
+	// keys set by a ClusterNamespaceLabel selecting the namespace take precedence
+	clusterNamespaceLabels := &danaiov1alpha1.ClusterNamespaceLabelList{}
+	if err := r.List(ctx, clusterNamespaceLabels); err != nil {
+		log.Error(err, "unable to list clusterNamespaceLabels")
+		return ctrl.Result{}, err
+	}
+	for key := range getClusterOwnedKeys(namespace, clusterNamespaceLabels.Items, labels, clusterSpecLabels) {
+		delete(labels, key)
+	}
+
+	// events that do not change what the namespace inherits are not written to it
+	if owned, _, ok := getAppliedKeys(namespace, inheritanceFieldManager); ok && equality.Semantic.DeepEqual(owned, labels) {
+		return ctrl.Result{}, nil
+	}
+
+	// labels that are no longer inherited are released by the apply
+	if err := applyNSLabels(ctx, r.Client, inheritanceFieldManager, namespace, labels, nil); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	return ctrl.Result{}, nil
+}
+
+// this function walks up the ancestors of the namespace and returns the labels propagated to
+// it by their NamespaceLabels. The value of the nearest ancestor wins, and cycles in the parent
+// annotations end the walk
+func getInheritedLabels(ctx context.Context, c client.Reader, parentAnnotation string, namespace *v1.Namespace) (map[string]inheritedLabel, error) {
+	inherited := make(map[string]inheritedLabel)
+	visited := map[string]bool{namespace.Name: true}
+
+	current := namespace
+	for {
+		parentName := current.Annotations[parentAnnotation]
+		if parentName == "" || visited[parentName] {
+			return inherited, nil
+		}
+		visited[parentName] = true
+
+		parent := &v1.Namespace{}
+		if err := c.Get(ctx, types.NamespacedName{Name: parentName}, parent); err != nil {
+			if errors.IsNotFound(err) {
+				return inherited, nil
+			}
+			return nil, err
+		}
+
+		namespaceLabels := &danaiov1alpha1.NamespaceLabelList{}
+		if err := c.List(ctx, namespaceLabels, client.InNamespace(parentName)); err != nil {
+			return nil, err
+		}
+		for _, namespaceLabel := range namespaceLabels.Items {
+			if namespaceLabel.Spec.Propagation == nil || !namespaceLabel.DeletionTimestamp.IsZero() {
+				continue
+			}
+			for _, key := range namespaceLabel.Spec.Propagation.Keys {
+				// only the labels active on the parent are propagated
+				val, ok := namespaceLabel.Status.ActiveLabels[key]
+				if _, found := inherited[key]; !ok || found {
+					continue
+				}
+				inherited[key] = inheritedLabel{
+					value:          val,
+					source:         fmt.Sprintf("%s/%s", namespaceLabel.Namespace, namespaceLabel.Name),
+					allowOverrides: namespaceLabel.Spec.Propagation.AllowOverrides,
+				}
+			}
+		}
+
+		current = parent
+	}
+}
+
+// isRequestedKey reports whether a NamespaceLabel that is not being deleted requests the label key
+func isRequestedKey(key string, namespaceLabels []danaiov1alpha1.NamespaceLabel) bool {
+	for _, namespaceLabel := range namespaceLabels {
+		if !namespaceLabel.DeletionTimestamp.IsZero() {
+			continue
+		}
+		if _, ok := namespaceLabel.Spec.Labels[key]; ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *InheritanceReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("inheritance").
+		For(&v1.Namespace{}).
+		Watches(&source.Kind{Type: &v1.Namespace{}}, handler.EnqueueRequestsFromMapFunc(r.findDescendantsForNamespace)).
+		Watches(&source.Kind{Type: &danaiov1alpha1.NamespaceLabel{}}, handler.EnqueueRequestsFromMapFunc(r.findDescendantsForNamespaceLabel)).
+		Watches(&source.Kind{Type: &danaiov1alpha1.ClusterNamespaceLabel{}}, handler.EnqueueRequestsFromMapFunc(r.findNamespacesForClusterNamespaceLabel),
+			builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Watches(&source.Kind{Type: &configv1alpha1.NamespacelabelConfig{}}, handler.EnqueueRequestsFromMapFunc(r.findAllNamespaces),
+			builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Complete(r)
+}
+
+// findDescendantsForNamespace maps a namespace event to reconcile requests for its descendants,
+// since a changed parent annotation moves the whole subtree
+func (r *InheritanceReconciler) findDescendantsForNamespace(namespace client.Object) []reconcile.Request {
+	return r.findDescendants(namespace.GetName(), false)
+}
+
+// findDescendantsForNamespaceLabel maps a NamespaceLabel event to reconcile requests for its
+// namespace, whose overrides may have changed, and for the descendants of its namespace
+func (r *InheritanceReconciler) findDescendantsForNamespaceLabel(namespaceLabel client.Object) []reconcile.Request {
+	return r.findDescendants(namespaceLabel.GetNamespace(), true)
+}
+
+// findDescendants returns reconcile requests for the namespaces below the given namespace,
+// and for the namespace itself when self is set
+func (r *InheritanceReconciler) findDescendants(name string, self bool) []reconcile.Request {
+	config, err := configv1alpha1.GetClusterConfig(context.TODO(), r.Client)
+	if err != nil {
+		return []reconcile.Request{}
+	}
+	namespaces := &v1.NamespaceList{}
+	if err := r.List(context.TODO(), namespaces); err != nil {
+		return []reconcile.Request{}
+	}
+
+	children := make(map[string][]string)
+	for _, item := range namespaces.Items {
+		if parent := item.Annotations[config.Spec.ParentAnnotationKey()]; parent != "" {
+			children[parent] = append(children[parent], item.Name)
+		}
+	}
+
+	requests := []reconcile.Request{}
+	if self {
+		requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Name: name}})
+	}
+	visited := map[string]bool{name: true}
+	queue := []string{name}
+	for len(queue) > 0 {
+		for _, child := range children[queue[0]] {
+			if visited[child] {
+				continue
+			}
+			visited[child] = true
+			queue = append(queue, child)
+			requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Name: child}})
+		}
+		queue = queue[1:]
+	}
+
+	return requests
+}
+
+// findNamespacesForClusterNamespaceLabel maps a ClusterNamespaceLabel event to reconcile requests for
+// the namespaces it selects or has labeled, whose inherited labels it may take precedence over
+func (r *InheritanceReconciler) findNamespacesForClusterNamespaceLabel(clusterNamespaceLabel client.Object) []reconcile.Request {
+	names, err := getClusterNamespaceLabelNamespaces(context.TODO(), r.Client, clusterNamespaceLabel.(*danaiov1alpha1.ClusterNamespaceLabel))
+	if err != nil {
+		return []reconcile.Request{}
+	}
+
+	requests := make([]reconcile.Request, len(names))
+	for i, name := range names {
+		requests[i] = reconcile.Request{
+			NamespacedName: types.NamespacedName{
+				Name: name,
+			},
+		}
+	}
+
+	return requests
+}
+
+// findAllNamespaces maps an event to reconcile requests for every namespace
+func (r *InheritanceReconciler) findAllNamespaces(obj client.Object) []reconcile.Request {
+	namespaces := &v1.NamespaceList{}
+	if err := r.List(context.TODO(), namespaces); err != nil {
+		return []reconcile.Request{}
+	}
+
+	requests := make([]reconcile.Request, len(namespaces.Items))
+	for i, item := range namespaces.Items {
+		requests[i] = reconcile.Request{
+			NamespacedName: types.NamespacedName{
+				Name: item.GetName(),
+			},
+		}
+	}
+
+	return requests
+}
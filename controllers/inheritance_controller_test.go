@@ -0,0 +1,239 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	configv1alpha1 "home-assignment/apis/config/v1alpha1"
+	danaiov1alpha1 "home-assignment/apis/namespacelabel/v1alpha1"
+)
+
+func generateChildNamespaceObject(name string, parent string) *v1.Namespace {
+	return &v1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Annotations: map[string]string{configv1alpha1.DefaultParentAnnotation: parent},
+		},
+	}
+}
+
+// generateInheritanceObjects returns a team namespace propagating two labels to a project
+// namespace, which overrides one of them, and to a namespace below the project
+func generateInheritanceObjects() []client.Object {
+	return []client.Object{
+		generateTenantNamespaceObject("team", nil),
+		generateChildNamespaceObject("project", "team"),
+		generateChildNamespaceObject("sub", "project"),
+		&danaiov1alpha1.NamespaceLabel{
+			ObjectMeta: metav1.ObjectMeta{Name: "team-labels", Namespace: "team"},
+			Spec: danaiov1alpha1.NamespaceLabelSpec{
+				Labels: map[string]string{"cost-center": "1234", "tier": "gold", "private": "true"},
+				Propagation: &danaiov1alpha1.Propagation{
+					Keys:           []string{"cost-center", "tier"},
+					AllowOverrides: false,
+				},
+			},
+			Status: danaiov1alpha1.NamespaceLabelStatus{
+				ActiveLabels: map[string]string{"cost-center": "1234", "tier": "gold", "private": "true"},
+			},
+		},
+		&danaiov1alpha1.NamespaceLabel{
+			ObjectMeta: metav1.ObjectMeta{Name: "team-overridable", Namespace: "team"},
+			Spec: danaiov1alpha1.NamespaceLabelSpec{
+				Labels: map[string]string{"env": "prod"},
+				Propagation: &danaiov1alpha1.Propagation{
+					Keys:           []string{"env"},
+					AllowOverrides: true,
+				},
+			},
+			Status: danaiov1alpha1.NamespaceLabelStatus{
+				ActiveLabels: map[string]string{"env": "prod"},
+			},
+		},
+		&danaiov1alpha1.NamespaceLabel{
+			ObjectMeta: metav1.ObjectMeta{Name: "project-labels", Namespace: "project"},
+			Spec: danaiov1alpha1.NamespaceLabelSpec{
+				Labels: map[string]string{"env": "dev", "tier": "silver"},
+			},
+		},
+	}
+}
+
+func TestInheritanceReconciler(t *testing.T) {
+	g := NewGomegaWithT(t)
+	RegisterFailHandler(ginkgo.Fail)
+
+	cl, s, err := setupClient(generateInheritanceObjects())
+	if err != nil {
+		t.Fatalf("Unable to add to scheme: %v", err)
+	}
+
+	// create an InheritanceReconciler object with the scheme and fake client
+	r := &InheritanceReconciler{cl, s}
+
+	for _, name := range []string{"team", "project", "sub"} {
+		if _, err := r.Reconcile(context.TODO(), reconcile.Request{NamespacedName: types.NamespacedName{Name: name}}); err != nil {
+			t.Fatalf("Unable to reconcile %s: %v", name, err)
+		}
+	}
+
+	// check that only the propagated labels are inherited, and that the override of
+	// the project is respected where the propagation allows it
+	expectedLabels := map[string]map[string]string{
+		"team":    nil,
+		"project": {"cost-center": "1234", "tier": "gold"},
+		"sub":     {"cost-center": "1234", "tier": "gold", "env": "prod"},
+	}
+	for name, labels := range expectedLabels {
+		namespace := &v1.Namespace{}
+		if err := r.Get(context.TODO(), types.NamespacedName{Name: name}, namespace); err != nil {
+			t.Fatalf("get: (%v)", err)
+		}
+		g.Expect(namespace.Labels).To(BeEquivalentTo(labels), "namespace %s", name)
+	}
+
+	// check that the descendants of the team are found for a cascade
+	g.Expect(r.findDescendants("team", false)).To(ConsistOf(
+		reconcile.Request{NamespacedName: types.NamespacedName{Name: "project"}},
+		reconcile.Request{NamespacedName: types.NamespacedName{Name: "sub"}},
+	))
+}
+
+func TestInheritanceReconcilerUnchanged(t *testing.T) {
+	g := NewGomegaWithT(t)
+	RegisterFailHandler(ginkgo.Fail)
+
+	cl, s, err := setupClient(generateInheritanceObjects())
+	if err != nil {
+		t.Fatalf("Unable to add to scheme: %v", err)
+	}
+
+	// create an InheritanceReconciler object with the scheme and fake client
+	r := &InheritanceReconciler{cl, s}
+
+	req := reconcile.Request{NamespacedName: types.NamespacedName{Name: "sub"}}
+	if _, err := r.Reconcile(context.TODO(), req); err != nil {
+		t.Fatalf("Unable to reconcile: %v", err)
+	}
+	namespace := &v1.Namespace{}
+	if err := r.Get(context.TODO(), req.NamespacedName, namespace); err != nil {
+		t.Fatalf("get: (%v)", err)
+	}
+	resourceVersion := namespace.ResourceVersion
+
+	// check that the namespace is not written when it already holds the inherited labels
+	if _, err := r.Reconcile(context.TODO(), req); err != nil {
+		t.Fatalf("Unable to reconcile: %v", err)
+	}
+	if err := r.Get(context.TODO(), req.NamespacedName, namespace); err != nil {
+		t.Fatalf("get: (%v)", err)
+	}
+	g.Expect(namespace.ResourceVersion).To(Equal(resourceVersion))
+
+	// check that a label that is no longer inherited is still released
+	team := &danaiov1alpha1.NamespaceLabel{}
+	if err := r.Get(context.TODO(), types.NamespacedName{Name: "team-overridable", Namespace: "team"}, team); err != nil {
+		t.Fatalf("get: (%v)", err)
+	}
+	team.Spec.Propagation.Keys = nil
+	if err := r.Update(context.TODO(), team); err != nil {
+		t.Fatalf("update: (%v)", err)
+	}
+	if _, err := r.Reconcile(context.TODO(), req); err != nil {
+		t.Fatalf("Unable to reconcile: %v", err)
+	}
+	if err := r.Get(context.TODO(), req.NamespacedName, namespace); err != nil {
+		t.Fatalf("get: (%v)", err)
+	}
+	g.Expect(namespace.Labels).To(Equal(map[string]string{"cost-center": "1234", "tier": "gold"}))
+}
+
+func TestFindNamespacesForClusterNamespaceLabel(t *testing.T) {
+	g := NewGomegaWithT(t)
+	RegisterFailHandler(ginkgo.Fail)
+
+	clusterNamespaceLabel := &danaiov1alpha1.ClusterNamespaceLabel{
+		ObjectMeta: metav1.ObjectMeta{Name: "projects"},
+		Spec: danaiov1alpha1.ClusterNamespaceLabelSpec{
+			NamespaceSelector:     &metav1.LabelSelector{},
+			NamespaceNamePatterns: []string{"proj*"},
+		},
+		Status: danaiov1alpha1.ClusterNamespaceLabelStatus{
+			Targets: []danaiov1alpha1.ClusterNamespaceLabelTarget{{Name: "sub"}},
+		},
+	}
+	cl, s, err := setupClient(append(generateInheritanceObjects(), clusterNamespaceLabel))
+	if err != nil {
+		t.Fatalf("Unable to add to scheme: %v", err)
+	}
+
+	// create an InheritanceReconciler object with the scheme and fake client
+	r := &InheritanceReconciler{cl, s}
+
+	// check that only the selected namespaces and the namespaces still labeled are enqueued
+	g.Expect(r.findNamespacesForClusterNamespaceLabel(clusterNamespaceLabel)).To(ConsistOf(
+		reconcile.Request{NamespacedName: types.NamespacedName{Name: "project"}},
+		reconcile.Request{NamespacedName: types.NamespacedName{Name: "sub"}},
+	))
+}
+
+func TestReconcilerInheritedConflict(t *testing.T) {
+	g := NewGomegaWithT(t)
+	RegisterFailHandler(ginkgo.Fail)
+
+	// the reconciler looks the namespace up with the namespace of the NamespaceLabel,
+	// which the fake client only matches when the fixture carries it in its metadata
+	obj := generateInheritanceObjects()
+	obj[1].SetNamespace("project")
+
+	cl, s, err := setupClient(obj)
+	if err != nil {
+		t.Fatalf("Unable to add to scheme: %v", err)
+	}
+
+	// create a NamespaceLabelReconciler object with the scheme and fake client
+	r := &NamespaceLabelReconciler{cl, s, record.NewFakeRecorder(10)}
+
+	req := reconcile.Request{NamespacedName: types.NamespacedName{Name: "project-labels", Namespace: "project"}}
+	if _, err := r.Reconcile(context.TODO(), req); err != nil {
+		t.Fatalf("Unable to reconcile: %v", err)
+	}
+
+	// the inherited tier can not be overridden, the inherited env can
+	namespaceLabel := &danaiov1alpha1.NamespaceLabel{}
+	if err := r.Get(context.TODO(), req.NamespacedName, namespaceLabel); err != nil {
+		t.Fatalf("get: (%v)", err)
+	}
+	g.Expect(namespaceLabel.Status.ActiveLabels).To(Equal(map[string]string{"env": "dev"}))
+	g.Expect(namespaceLabel.Status.KeyResults).To(ContainElement(danaiov1alpha1.KeyResult{
+		Key:     "tier",
+		Type:    danaiov1alpha1.KeyTypeLabel,
+		Result:  danaiov1alpha1.KeyResultConflict,
+		Message: "key is inherited from NamespaceLabel team/team-labels",
+	}))
+}
@@ -0,0 +1,254 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	danaiov1alpha1 "home-assignment/apis/namespacelabel/v1alpha1"
+)
+
+// hasPrecedence reports whether object a with priority aPriority wins over object b with
+// priority bPriority when both request the same key. The higher priority wins, then the
+// older object, and the name breaks any remaining tie so the result is always deterministic
+func hasPrecedence(aPriority int32, a metav1.Object, bPriority int32, b metav1.Object) bool {
+	if aPriority != bPriority {
+		return aPriority > bPriority
+	}
+
+	aCreated, bCreated := a.GetCreationTimestamp(), b.GetCreationTimestamp()
+	if !aCreated.Equal(&bCreated) {
+		return aCreated.Before(&bCreated)
+	}
+
+	return a.GetName() < b.GetName()
+}
+
+// this function splits the requested keys of a NamespaceLabel into the keys it owns and the keys
+// that are owned by another NamespaceLabel in the same namespace with precedence over it.
+// The second map holds the conflicting keys and a message naming the owning NamespaceLabel
+func (r *NamespaceLabelReconciler) getOwnedLabels(namespaceLabel *danaiov1alpha1.NamespaceLabel, others []danaiov1alpha1.NamespaceLabel, reqLabels map[string]string, keysOf func(*danaiov1alpha1.NamespaceLabel) map[string]string) (map[string]string, map[string]string) {
+	ownedLabels := make(map[string]string)
+	conflictLabels := make(map[string]string)
+
+	for key, val := range reqLabels {
+		// find the NamespaceLabel with the highest precedence that requests the key
+		owner := namespaceLabel
+		for i := range others {
+			other := &others[i]
+			if other.UID == namespaceLabel.UID || !other.DeletionTimestamp.IsZero() {
+				continue
+			}
+			if _, ok := keysOf(other)[key]; ok && hasPrecedence(other.Spec.Priority, other, owner.Spec.Priority, owner) {
+				owner = other
+			}
+		}
+
+		if owner != namespaceLabel {
+			conflictLabels[key] = fmt.Sprintf("key is owned by NamespaceLabel %s", owner.Name)
+			continue
+		}
+		ownedLabels[key] = val
+	}
+
+	return ownedLabels, conflictLabels
+}
+
+// specLabels returns the labels requested by a NamespaceLabel
+func specLabels(namespaceLabel *danaiov1alpha1.NamespaceLabel) map[string]string {
+	return namespaceLabel.Spec.Labels
+}
+
+// specAnnotations returns the annotations requested by a NamespaceLabel
+func specAnnotations(namespaceLabel *danaiov1alpha1.NamespaceLabel) map[string]string {
+	return namespaceLabel.Spec.Annotations
+}
+
+// this function returns the requested keys of a namespace that are owned by a
+// ClusterNamespaceLabel selecting the namespace, along with a message naming the
+// owner. Keys set by platform teams through a ClusterNamespaceLabel always take
+// precedence over the keys requested by a NamespaceLabel
+func getClusterOwnedKeys(namespace *v1.Namespace, clusterNamespaceLabels []danaiov1alpha1.ClusterNamespaceLabel, reqKeys map[string]string, keysOf func(*danaiov1alpha1.ClusterNamespaceLabel) map[string]string) map[string]string {
+	ownedKeys := make(map[string]string)
+
+	for i := range clusterNamespaceLabels {
+		clusterNamespaceLabel := &clusterNamespaceLabels[i]
+		if !clusterNamespaceLabel.DeletionTimestamp.IsZero() {
+			continue
+		}
+		if matches, err := clusterNamespaceLabel.MatchesNamespace(namespace.Name, namespace.Labels); err != nil || !matches {
+			continue
+		}
+
+		for key := range keysOf(clusterNamespaceLabel) {
+			if _, ok := reqKeys[key]; ok {
+				ownedKeys[key] = fmt.Sprintf("key is owned by ClusterNamespaceLabel %s", clusterNamespaceLabel.Name)
+			}
+		}
+	}
+
+	return ownedKeys
+}
+
+// clusterSpecLabels returns the labels requested by a ClusterNamespaceLabel
+func clusterSpecLabels(clusterNamespaceLabel *danaiov1alpha1.ClusterNamespaceLabel) map[string]string {
+	return clusterNamespaceLabel.Spec.Labels
+}
+
+// clusterSpecAnnotations returns the annotations requested by a ClusterNamespaceLabel
+func clusterSpecAnnotations(clusterNamespaceLabel *danaiov1alpha1.ClusterNamespaceLabel) map[string]string {
+	return clusterNamespaceLabel.Spec.Annotations
+}
+
+// this function returns the requested keys that are already set on the namespace to a different
+// value by someone else than a NamespaceLabel or ClusterNamespaceLabel, along with their pre-existing
+// value. Keys that are active for the NamespaceLabel itself or for another source are not pre-existing,
+// and keys already set to the requested value are shared with their owner rather than overwritten
+func getPreExistingKeys(namespaceLabel *danaiov1alpha1.NamespaceLabel, others []danaiov1alpha1.NamespaceLabel, clusterNamespaceLabels []danaiov1alpha1.ClusterNamespaceLabel, reqKeys map[string]string, nsKeys map[string]string, activeOf func(*danaiov1alpha1.NamespaceLabel) map[string]string, clusterActiveOf func(*danaiov1alpha1.ClusterNamespaceLabelTarget) map[string]string) map[string]string {
+	preExistingKeys := make(map[string]string)
+
+	for key, reqVal := range reqKeys {
+		val, ok := nsKeys[key]
+		if !ok || val == reqVal {
+			continue
+		}
+		if _, ok := activeOf(namespaceLabel)[key]; ok {
+			continue
+		}
+		if !isManagedKey(key, namespaceLabel, others, clusterNamespaceLabels, activeOf, clusterActiveOf) {
+			preExistingKeys[key] = val
+		}
+	}
+
+	return preExistingKeys
+}
+
+// isManagedKey reports whether the key is active for another NamespaceLabel in the namespace
+// or for a ClusterNamespaceLabel targeting the namespace
+func isManagedKey(key string, namespaceLabel *danaiov1alpha1.NamespaceLabel, others []danaiov1alpha1.NamespaceLabel, clusterNamespaceLabels []danaiov1alpha1.ClusterNamespaceLabel, activeOf func(*danaiov1alpha1.NamespaceLabel) map[string]string, clusterActiveOf func(*danaiov1alpha1.ClusterNamespaceLabelTarget) map[string]string) bool {
+	for i := range others {
+		if others[i].UID == namespaceLabel.UID {
+			continue
+		}
+		if _, ok := activeOf(&others[i])[key]; ok {
+			return true
+		}
+	}
+
+	for i := range clusterNamespaceLabels {
+		for j := range clusterNamespaceLabels[i].Status.Targets {
+			target := &clusterNamespaceLabels[i].Status.Targets[j]
+			if target.Name != namespaceLabel.Namespace {
+				continue
+			}
+			if _, ok := clusterActiveOf(target)[key]; ok {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// activeLabels returns the labels a NamespaceLabel applied to the namespace
+func activeLabels(namespaceLabel *danaiov1alpha1.NamespaceLabel) map[string]string {
+	return namespaceLabel.Status.ActiveLabels
+}
+
+// activeAnnotations returns the annotations a NamespaceLabel applied to the namespace
+func activeAnnotations(namespaceLabel *danaiov1alpha1.NamespaceLabel) map[string]string {
+	return namespaceLabel.Status.ActiveAnnotations
+}
+
+// clusterActiveLabels returns the labels a ClusterNamespaceLabel applied to a namespace
+func clusterActiveLabels(target *danaiov1alpha1.ClusterNamespaceLabelTarget) map[string]string {
+	return target.ActiveLabels
+}
+
+// clusterActiveAnnotations returns the annotations a ClusterNamespaceLabel applied to a namespace
+func clusterActiveAnnotations(target *danaiov1alpha1.ClusterNamespaceLabelTarget) map[string]string {
+	return target.ActiveAnnotations
+}
+
+// this function handles the pre-existing keys according to the conflict policy of the NamespaceLabel.
+// With Takeover the pre-existing values are recorded in originalKeys so they can be restored later,
+// otherwise the keys are moved from reqKeys to conflictKeys and left untouched on the namespace
+func resolvePreExistingKeys(policy danaiov1alpha1.ConflictPolicy, preExistingKeys map[string]string, reqKeys map[string]string, conflictKeys map[string]string, originalKeys map[string]string) map[string]string {
+	if policy == danaiov1alpha1.ConflictPolicyTakeover {
+		if len(preExistingKeys) > 0 && originalKeys == nil {
+			originalKeys = make(map[string]string)
+		}
+		for key, val := range preExistingKeys {
+			if _, ok := originalKeys[key]; !ok {
+				originalKeys[key] = val
+			}
+		}
+		return originalKeys
+	}
+
+	for key, val := range preExistingKeys {
+		delete(reqKeys, key)
+		conflictKeys[key] = fmt.Sprintf("key is already set on the namespace to %q", val)
+	}
+
+	return originalKeys
+}
+
+// this function splits the recorded original values into the values of keys that are no longer
+// applied by the NamespaceLabel, which should be restored, and the values that are still kept.
+// Keys taken over by another source are dropped without being restored, since the new owner
+// overwrites them anyway
+func getReleasedOriginals(originalKeys map[string]string, reqKeys map[string]string, conflictKeys map[string]string) (map[string]string, map[string]string) {
+	restoreKeys := make(map[string]string)
+	keptKeys := make(map[string]string)
+
+	for key, val := range originalKeys {
+		if _, ok := reqKeys[key]; ok {
+			keptKeys[key] = val
+			continue
+		}
+		if _, ok := conflictKeys[key]; !ok {
+			restoreKeys[key] = val
+		}
+	}
+
+	return restoreKeys, keptKeys
+}
+
+// this function returns the values to set back on the namespace once the keys of a deleted
+// NamespaceLabel are released, following the deletion policy of each key: retained keys keep
+// their active value and restored keys get their original value back. The values are applied
+// by the retained field manager, which no source reconciles, so the keys are left unmanaged
+func getDeletionKeys(namespaceLabel *danaiov1alpha1.NamespaceLabel, activeKeys map[string]string, originalKeys map[string]string) map[string]string {
+	keys := make(map[string]string)
+
+	for key, val := range originalKeys {
+		if namespaceLabel.GetDeletionPolicy(key) == danaiov1alpha1.DeletionPolicyRestoreOriginal {
+			keys[key] = val
+		}
+	}
+	for key, val := range activeKeys {
+		if namespaceLabel.GetDeletionPolicy(key) == danaiov1alpha1.DeletionPolicyRetain {
+			keys[key] = val
+		}
+	}
+
+	return keys
+}
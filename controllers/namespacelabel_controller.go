@@ -18,31 +18,58 @@ package controllers
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
 
 	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
+	configv1alpha1 "home-assignment/apis/config/v1alpha1"
 	danaiov1alpha1 "home-assignment/apis/namespacelabel/v1alpha1"
 )
 
 const NamespaceLabelFinalizer = "dana.io/namespacelabel-finalizer"
 
+// retainedFieldManager is the field manager of the keys left on a namespace by deleted NamespaceLabels
+const retainedFieldManager = "namespacelabel-retained"
+
+// maxFieldManagerLength is the maximum length of a field manager accepted by the API server
+const maxFieldManagerLength = 128
+
 // NamespaceLabelReconciler reconciles a NamespaceLabel object
 type NamespaceLabelReconciler struct {
 	client.Client
-	Scheme *runtime.Scheme
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
 }
 
 //+kubebuilder:rbac:groups=dana.io.dana.io,resources=namespacelabels,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=dana.io.dana.io,resources=namespacelabels/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=dana.io.dana.io,resources=namespacelabels/finalizers,verbs=update
 //+kubebuilder:rbac:groups=*,resources=namespaces,verbs=get;list;watch;update;patch
+//+kubebuilder:rbac:groups=config.dana.io,resources=namespacelabelconfigs,verbs=get;list;watch
+//+kubebuilder:rbac:groups=dana.io.dana.io,resources=clusternamespacelabels,verbs=get;list;watch
+//+kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+//+kubebuilder:rbac:groups=dana.io.dana.io,resources=namespacelabelrevisions,verbs=get;list;watch;create;delete
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
@@ -73,8 +100,24 @@ func (r *NamespaceLabelReconciler) Reconcile(ctx context.Context, req ctrl.Reque
 	}
 
 	if err := r.Get(ctx, nsNamespacedName, &namespace); err != nil {
-		log.Error(err, "unable to fetch namespace")
-		return ctrl.Result{}, client.IgnoreNotFound(err)
+		if !errors.IsNotFound(err) {
+			log.Error(err, "unable to fetch namespace")
+			return ctrl.Result{}, err
+		}
+		// without a namespace there are no keys left to clean up, the NamespaceLabel
+		// must not hold on to its finalizer or it is never removed
+		return ctrl.Result{}, r.releaseFinalizer(ctx, &namespaceLabel, ReasonNamespaceNotFound,
+			fmt.Sprintf("Namespace %s not found, removed the finalizer since there is nothing to clean up", req.Namespace))
+	}
+
+	// a terminating namespace takes its keys with it, so labels are no longer written to it
+	if !namespace.DeletionTimestamp.IsZero() {
+		if namespaceLabel.DeletionTimestamp.IsZero() {
+			log.Info("Skipping terminating namespace", "namespace", namespace.Name)
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, r.releaseFinalizer(ctx, &namespaceLabel, ReasonNamespaceTerminating,
+			fmt.Sprintf("Namespace %s is terminating, removed the finalizer without releasing its keys", namespace.Name))
 	}
 
 	// examine DeletionTimestamp to determine if object is under deletion
@@ -93,29 +136,207 @@ func (r *NamespaceLabelReconciler) Reconcile(ctx context.Context, req ctrl.Reque
 		return ctrl.Result{}, nil
 	}
 
-	// get labels to add and delete and update the namespace
-	addLabels, delLabels := r.getNamespaceLabelsDiffs(&namespaceLabel)
-	if err := r.updateNSLabels(ctx, &namespace, addLabels, delLabels); err != nil {
+	// a suspended NamespaceLabel is left alone until it is resumed, only its deletion is handled
+	if namespaceLabel.Spec.Suspend {
+		origStatus := namespaceLabel.Status.DeepCopy()
+		r.setSuspendedStatus(&namespaceLabel)
+		if equality.Semantic.DeepEqual(origStatus, &namespaceLabel.Status) {
+			return ctrl.Result{}, nil
+		}
+		if err := r.Status().Update(ctx, &namespaceLabel); err != nil {
+			log.Error(err, "unable to update namespaceLabel status")
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{}, nil
+	}
+
+	// fetch the cluster policy to filter out labels it no longer allows
+	config, err := configv1alpha1.GetClusterConfig(ctx, r)
+	if err != nil {
+		log.Error(err, "unable to fetch namespacelabelconfig")
+		return ctrl.Result{}, err
+	}
+	// templated values are rendered from the metadata of the namespace before they are checked and diffed
+	renderedLabels, failedLabels := renderKeys(namespaceLabel.Spec.Labels, &namespace, true, config.Spec.Defaults.NormalizeValue)
+	renderedAnnotations, failedAnnotations := renderKeys(namespaceLabel.Spec.Annotations, &namespace, false, nil)
+	reqLabels, rejectedLabels := r.getAllowedLabels(ctx, config, renderedLabels)
+	reqAnnotations, rejectedAnnotations := r.getAllowedLabels(ctx, config, renderedAnnotations)
+
+	// keys outside of their scheduled window are left off the namespace until the next transition
+	now := time.Now()
+	unscheduledLabels := getUnscheduledKeys(namespaceLabel.Spec.Schedules, reqLabels, now)
+	unscheduledAnnotations := getUnscheduledKeys(namespaceLabel.Spec.Schedules, reqAnnotations, now)
+	transitions := getUpcomingTransitions(namespaceLabel.Spec.Schedules, now)
+	result := ctrl.Result{RequeueAfter: nextTransitionAfter(transitions, now)}
+
+	// fetch the other NamespaceLabels in the namespace and drop the keys they own
+	otherNamespaceLabels := &danaiov1alpha1.NamespaceLabelList{}
+	if err := r.List(ctx, otherNamespaceLabels, client.InNamespace(req.NamespacedName.Namespace)); err != nil {
+		log.Error(err, "unable to list namespaceLabels")
 		return ctrl.Result{}, err
 	}
+	reqLabels, conflictLabels := r.getOwnedLabels(&namespaceLabel, otherNamespaceLabels.Items, reqLabels, specLabels)
+	reqAnnotations, conflictAnnotations := r.getOwnedLabels(&namespaceLabel, otherNamespaceLabels.Items, reqAnnotations, specAnnotations)
+
+	// keys set by a ClusterNamespaceLabel selecting the namespace take precedence as well
+	clusterNamespaceLabels := &danaiov1alpha1.ClusterNamespaceLabelList{}
+	if err := r.List(ctx, clusterNamespaceLabels); err != nil {
+		log.Error(err, "unable to list clusterNamespaceLabels")
+		return ctrl.Result{}, err
+	}
+	for key, msg := range getClusterOwnedKeys(&namespace, clusterNamespaceLabels.Items, reqLabels, clusterSpecLabels) {
+		delete(reqLabels, key)
+		conflictLabels[key] = msg
+	}
+	for key, msg := range getClusterOwnedKeys(&namespace, clusterNamespaceLabels.Items, reqAnnotations, clusterSpecAnnotations) {
+		delete(reqAnnotations, key)
+		conflictAnnotations[key] = msg
+	}
+
+	// labels inherited from an ancestor namespace are owned by the ancestor, unless its propagation allows overrides
+	inheritedLabels, err := getInheritedLabels(ctx, r, config.Spec.ParentAnnotationKey(), &namespace)
+	if err != nil {
+		log.Error(err, "unable to get inherited labels")
+		return ctrl.Result{}, err
+	}
+	for key, inherited := range inheritedLabels {
+		if _, ok := reqLabels[key]; ok && !inherited.allowOverrides {
+			delete(reqLabels, key)
+			conflictLabels[key] = fmt.Sprintf("key is inherited from NamespaceLabel %s", inherited.source)
+		}
+	}
+
+	origStatus := namespaceLabel.Status.DeepCopy()
+	meta.RemoveStatusCondition(&namespaceLabel.Status.Conditions, danaiov1alpha1.ConditionSuspended)
+
+	// report when the keys exceed limits that were lowered after the NamespaceLabel was admitted,
+	// counting the rendered keys like the webhook does
+	rendered := namespaceLabel.DeepCopy()
+	rendered.Spec.Labels, rendered.Spec.Annotations = renderedLabels, renderedAnnotations
+	managedLabels, managedAnnotations := rendered.GetManagedKeys(otherNamespaceLabels.Items, clusterNamespaceLabels.Items)
+	quotaErrs := config.Spec.CheckNamespaceLimits(namespace.Name, managedLabels, managedAnnotations)
+	if err := config.Spec.CheckLabelsLimit(namespace.Name, renderedLabels); err != nil {
+		quotaErrs = append(quotaErrs, err)
+	}
+	r.setQuotaCondition(&namespaceLabel, quotaErrs)
+
+	// keys already set on the namespace by someone else are handled according to the conflict policy
+	preExistingLabels := getPreExistingKeys(&namespaceLabel, otherNamespaceLabels.Items, clusterNamespaceLabels.Items, reqLabels, namespace.Labels, activeLabels, clusterActiveLabels)
+	preExistingAnnotations := getPreExistingKeys(&namespaceLabel, otherNamespaceLabels.Items, clusterNamespaceLabels.Items, reqAnnotations, namespace.Annotations, activeAnnotations, clusterActiveAnnotations)
+	// an overridden inherited value is replaced rather than preserved
+	for key := range inheritedLabels {
+		delete(preExistingLabels, key)
+	}
+	policy := namespaceLabel.Spec.ConflictPolicy
+	namespaceLabel.Status.OriginalLabels = resolvePreExistingKeys(policy, preExistingLabels, reqLabels, conflictLabels, namespaceLabel.Status.OriginalLabels)
+	namespaceLabel.Status.OriginalAnnotations = resolvePreExistingKeys(policy, preExistingAnnotations, reqAnnotations, conflictAnnotations, namespaceLabel.Status.OriginalAnnotations)
+
+	keyResults := append(
+		r.getKeyResults(danaiov1alpha1.KeyTypeLabel, reqLabels, rejectedLabels, conflictLabels),
+		r.getKeyResults(danaiov1alpha1.KeyTypeAnnotation, reqAnnotations, rejectedAnnotations, conflictAnnotations)...,
+	)
+	keyResults = append(keyResults, r.getTemplateResults(danaiov1alpha1.KeyTypeLabel, failedLabels)...)
+	keyResults = append(keyResults, r.getTemplateResults(danaiov1alpha1.KeyTypeAnnotation, failedAnnotations)...)
+	keyResults = append(keyResults, scheduleResults(danaiov1alpha1.KeyTypeLabel, unscheduledLabels)...)
+	keyResults = append(keyResults, scheduleResults(danaiov1alpha1.KeyTypeAnnotation, unscheduledAnnotations)...)
+	namespaceLabel.Status.UpcomingTransitions = nilIfNoTransitions(transitions)
+
+	// with the Fail policy nothing is synced until the pre-existing keys are removed from the namespace,
+	// the namespace watch triggers a new reconcile once that happens
+	if policy == danaiov1alpha1.ConflictPolicyFail && len(preExistingLabels)+len(preExistingAnnotations) > 0 {
+		keys := append(sortedKeys(preExistingLabels), sortedKeys(preExistingAnnotations)...)
+		r.setSyncFailedStatus(&namespaceLabel, keyResults, fmt.Errorf("keys already set on the namespace: %s", strings.Join(keys, ", ")))
+		if equality.Semantic.DeepEqual(origStatus, &namespaceLabel.Status) {
+			return result, nil
+		}
+		if err := r.Status().Update(ctx, &namespaceLabel); err != nil {
+			log.Error(err, "unable to update namespaceLabel status")
+			return ctrl.Result{}, err
+		}
+		return result, nil
+	}
+
+	// get labels and annotations to add and delete and update the namespace
+	addLabels, delLabels := getNamespaceLabelsDiffs(reqLabels, namespaceLabel.Status.ActiveLabels)
+	addAnnotations, delAnnotations := getNamespaceLabelsDiffs(reqAnnotations, namespaceLabel.Status.ActiveAnnotations)
+
+	// restore active labels and annotations that were changed or removed on the namespace out-of-band
+	for key, val := range getNamespaceLabelsDrift(reqLabels, namespaceLabel.Status.ActiveLabels, namespace.ObjectMeta.Labels) {
+		addLabels[key] = val
+	}
+	for key, val := range getNamespaceLabelsDrift(reqAnnotations, namespaceLabel.Status.ActiveAnnotations, namespace.ObjectMeta.Annotations) {
+		addAnnotations[key] = val
+	}
+
+	// apply the owned keys when anything changed, keys owned by another source
+	// are released without being deleted
+	changed := len(addLabels)+len(delLabels)+len(addAnnotations)+len(delAnnotations) > 0
+
+	// in dry run the changes are recorded in the status instead of being made to the namespace
+	if namespaceLabel.Spec.DryRun {
+		namespaceLabel.Status.PlannedChanges = getPlannedChanges(addLabels, delLabels, addAnnotations, delAnnotations)
+		namespaceLabel.Status.OriginalLabels = origStatus.OriginalLabels
+		namespaceLabel.Status.OriginalAnnotations = origStatus.OriginalAnnotations
+		r.setDryRunStatus(&namespaceLabel, keyResults, changed)
+		if equality.Semantic.DeepEqual(origStatus, &namespaceLabel.Status) {
+			return result, nil
+		}
+		if err := r.Status().Update(ctx, &namespaceLabel); err != nil {
+			log.Error(err, "unable to update namespaceLabel status")
+			return ctrl.Result{}, err
+		}
+		return result, nil
+	}
+	namespaceLabel.Status.PlannedChanges = nil
+
+	if changed {
+		if err := applyNSLabels(ctx, r.Client, namespaceLabelFieldManager(&namespaceLabel), &namespace, reqLabels, reqAnnotations); err != nil {
+			// record the failure in the status so the user can tell why the keys are not active
+			r.setSyncFailedStatus(&namespaceLabel, keyResults, err)
+			if statusErr := r.Status().Update(ctx, &namespaceLabel); statusErr != nil {
+				log.Error(statusErr, "unable to update namespaceLabel status")
+			}
+			return ctrl.Result{}, err
+		}
+	}
+
+	// restore the original values of taken over keys that were released
+	restoreLabels, keptLabels := getReleasedOriginals(namespaceLabel.Status.OriginalLabels, reqLabels, conflictLabels)
+	restoreAnnotations, keptAnnotations := getReleasedOriginals(namespaceLabel.Status.OriginalAnnotations, reqAnnotations, conflictAnnotations)
+	if len(restoreLabels)+len(restoreAnnotations) > 0 {
+		if err := restoreNSLabels(ctx, r.Client, &namespace, restoreLabels, restoreAnnotations); err != nil {
+			r.setSyncFailedStatus(&namespaceLabel, keyResults, err)
+			if statusErr := r.Status().Update(ctx, &namespaceLabel); statusErr != nil {
+				log.Error(statusErr, "unable to update namespaceLabel status")
+			}
+			return ctrl.Result{}, err
+		}
+	}
+	namespaceLabel.Status.OriginalLabels = nilIfEmpty(keptLabels)
+	namespaceLabel.Status.OriginalAnnotations = nilIfEmpty(keptAnnotations)
 
 	// update status of namespaceLabel to match current state
-	namespaceLabel.Status.ActiveLabels = namespaceLabel.Spec.Labels
+	namespaceLabel.Status.ActiveLabels = reqLabels
+	namespaceLabel.Status.ActiveAnnotations = reqAnnotations
+	r.setSyncedStatus(&namespaceLabel, keyResults, changed)
 
-	if err := r.Status().Update(ctx, &namespaceLabel); err != nil {
-		log.Error(err, "unable to update namespaceLabel status")
+	// record the applied keys in the revision history
+	if err := r.recordRevision(ctx, &namespaceLabel, config.Spec.GetRevisionHistoryLimit()); err != nil {
 		return ctrl.Result{}, err
 	}
 
-	return ctrl.Result{}, nil
-}
+	// skip the status update when nothing changed, to avoid triggering another reconcile
+	if equality.Semantic.DeepEqual(origStatus, &namespaceLabel.Status) {
+		return result, nil
+	}
 
-func (r *NamespaceLabelReconciler) deleteLabels(namespaceLabel *danaiov1alpha1.NamespaceLabel, namespace *v1.Namespace) {
-	// delete the labels from the namespace
-	actLabels := namespaceLabel.Status.ActiveLabels
-	for key := range actLabels {
-		delete(namespace.ObjectMeta.Labels, key)
+	if err := r.Status().Update(ctx, &namespaceLabel); err != nil {
+		log.Error(err, "unable to update namespaceLabel status")
+		return ctrl.Result{}, err
 	}
+
+	// requeue at the next scheduled transition
+	return result, nil
 }
 
 func (r *NamespaceLabelReconciler) deleteFinalizer(ctx context.Context, namespaceLabel *danaiov1alpha1.NamespaceLabel, namespace *v1.Namespace) error {
@@ -123,8 +344,22 @@ func (r *NamespaceLabelReconciler) deleteFinalizer(ctx context.Context, namespac
 	log.Info("Handling finalizer deletion")
 
 	if controllerutil.ContainsFinalizer(namespaceLabel, NamespaceLabelFinalizer) {
-		// our finalizer is present, so lets handle any external dependency
-		r.deleteLabels(namespaceLabel, namespace)
+		// put back the retained keys and the values that were set on the namespace before
+		// they were taken over, according to the deletion policy of each key. They are applied
+		// under their own field manager before the keys are released, since a write of an
+		// unchanged value would not take them over and the release would remove them
+		labels := getDeletionKeys(namespaceLabel, namespaceLabel.Status.ActiveLabels, namespaceLabel.Status.OriginalLabels)
+		annotations := getDeletionKeys(namespaceLabel, namespaceLabel.Status.ActiveAnnotations, namespaceLabel.Status.OriginalAnnotations)
+		if len(labels)+len(annotations) > 0 {
+			if err := retainNSLabels(ctx, r.Client, namespace, labels, annotations); err != nil {
+				return err
+			}
+		}
+
+		// our finalizer is present, so release every key we applied to the namespace
+		if err := applyNSLabels(ctx, r.Client, namespaceLabelFieldManager(namespaceLabel), namespace, nil, nil); err != nil {
+			return err
+		}
 
 		// remove our finalizer from the list and update it
 		controllerutil.RemoveFinalizer(namespaceLabel, NamespaceLabelFinalizer)
@@ -132,12 +367,27 @@ func (r *NamespaceLabelReconciler) deleteFinalizer(ctx context.Context, namespac
 			log.Error(err, "failed to update namespaceLabel")
 			return err
 		}
+	}
+	return nil
+}
 
-		if err := r.Update(ctx, namespace); err != nil {
-			log.Error(err, "failed to update namespace")
-			return err
-		}
+// releaseFinalizer removes the finalizer without touching the namespace, for when the namespace
+// is gone or going, and records an event explaining why
+func (r *NamespaceLabelReconciler) releaseFinalizer(ctx context.Context, namespaceLabel *danaiov1alpha1.NamespaceLabel, reason string, message string) error {
+	log := log.FromContext(ctx)
+
+	if !controllerutil.ContainsFinalizer(namespaceLabel, NamespaceLabelFinalizer) {
+		return nil
+	}
+
+	log.Info("Removing finalizer", "reason", reason)
+	controllerutil.RemoveFinalizer(namespaceLabel, NamespaceLabelFinalizer)
+	if err := r.Update(ctx, namespaceLabel); err != nil {
+		log.Error(err, "failed to update namespaceLabel")
+		return client.IgnoreNotFound(err)
 	}
+	r.Recorder.Event(namespaceLabel, v1.EventTypeNormal, reason, message)
+
 	return nil
 }
 
@@ -157,16 +407,77 @@ func (r *NamespaceLabelReconciler) addFinalizer(ctx context.Context, namespaceLa
 	return nil
 }
 
-// this function compares the status and the spec of a NamespaceLabel object
-// and returns two maps: one map indicates which labels to add/amend
-// the second map indicates which labels to delete from the namespace
-func (r *NamespaceLabelReconciler) getNamespaceLabelsDiffs(namespaceLabel *danaiov1alpha1.NamespaceLabel) (map[string]string, map[string]string) {
+// this function renders the templated values of the requested labels or annotations from the
+// metadata of the namespace and returns two maps: one map holds the rendered keys, the second
+// map holds the keys that failed to render, or rendered to an invalid label value, and the reason.
+// Rendered values are passed through normalize when it is set, as the webhook skips templates
+func renderKeys(keys map[string]string, namespace *v1.Namespace, isLabel bool, normalize func(string) string) (map[string]string, map[string]string) {
+	renderedKeys := make(map[string]string)
+	failedKeys := make(map[string]string)
+
+	for key, val := range keys {
+		rendered, err := danaiov1alpha1.RenderValue(val, namespace)
+		if err != nil {
+			failedKeys[key] = fmt.Sprintf("invalid template: %v", err)
+			continue
+		}
+		if normalize != nil && danaiov1alpha1.IsTemplate(val) {
+			rendered = normalize(rendered)
+		}
+		if msgs := validation.IsValidLabelValue(rendered); isLabel && len(msgs) > 0 {
+			failedKeys[key] = fmt.Sprintf("template rendered to invalid label value %q: %s", rendered, strings.Join(msgs, "; "))
+			continue
+		}
+		renderedKeys[key] = rendered
+	}
+
+	return renderedKeys, failedKeys
+}
+
+// this function filters the requested labels or annotations through the cluster
+// policy and returns two maps: one map holds the keys the policy allows to be set
+// on the namespace, the second map holds the rejected keys and the rejection reason
+func (r *NamespaceLabelReconciler) getAllowedLabels(ctx context.Context, config *configv1alpha1.NamespacelabelConfig, labels map[string]string) (map[string]string, map[string]string) {
+	log := log.FromContext(ctx)
+
+	allowedLabels := make(map[string]string)
+	rejectedLabels := make(map[string]string)
+	for key, val := range labels {
+		if err := config.Spec.CheckLabel(key, val); err != nil {
+			log.Info("Skipping label denied by cluster policy", "key", key, "reason", err.Error())
+			rejectedLabels[key] = err.Error()
+			continue
+		}
+		allowedLabels[key] = val
+	}
+
+	return allowedLabels, rejectedLabels
+}
+
+// this function returns the planned changes of a dry run from the keys to add and delete,
+// or nil when the namespace is already in sync
+func getPlannedChanges(addLabels map[string]string, delLabels map[string]string, addAnnotations map[string]string, delAnnotations map[string]string) *danaiov1alpha1.PlannedChanges {
+	if len(addLabels)+len(delLabels)+len(addAnnotations)+len(delAnnotations) == 0 {
+		return nil
+	}
+
+	// copy the maps, the keys to add may share their map with the requested keys
+	return &danaiov1alpha1.PlannedChanges{
+		AddLabels:         copyKeys(addLabels),
+		DeleteLabels:      copyKeys(delLabels),
+		AddAnnotations:    copyKeys(addAnnotations),
+		DeleteAnnotations: copyKeys(delAnnotations),
+	}
+}
+
+// this function compares the requested labels and the active labels of a NamespaceLabel
+// object and returns two maps: one map indicates which labels to add/amend
+// the second map indicates which labels to delete from the namespace.
+// The same comparison is used for annotations
+func getNamespaceLabelsDiffs(reqLabels map[string]string, actLabels map[string]string) (map[string]string, map[string]string) {
 	addLabels := make(map[string]string)
 	delLabels := make(map[string]string)
 
-	reqLabels := namespaceLabel.Spec.Labels
-	actLabels := namespaceLabel.Status.ActiveLabels
-
 	if actLabels == nil {
 		addLabels = reqLabels
 		return addLabels, delLabels
@@ -191,37 +502,250 @@ func (r *NamespaceLabelReconciler) getNamespaceLabelsDiffs(namespaceLabel *danai
 	return addLabels, delLabels
 }
 
-func (r *NamespaceLabelReconciler) updateNSLabels(ctx context.Context, namespace *v1.Namespace, addLabels map[string]string, delLabels map[string]string) error {
+// this function compares the active labels of a NamespaceLabel object with the labels
+// currently set on the namespace and returns the labels that were changed or removed
+// on the namespace by someone else and are still requested.
+// The same comparison is used for annotations
+func getNamespaceLabelsDrift(reqLabels map[string]string, actLabels map[string]string, nsLabels map[string]string) map[string]string {
+	driftLabels := make(map[string]string)
+
+	for actKey, actVal := range actLabels {
+		if reqVal, ok := reqLabels[actKey]; !ok || reqVal != actVal {
+			continue
+		}
+		if nsVal, ok := nsLabels[actKey]; !ok || nsVal != actVal {
+			driftLabels[actKey] = actVal
+		}
+	}
+
+	return driftLabels
+}
+
+// this function sends the complete set of labels and annotations owned by a field manager
+// to the namespace as a server-side apply patch. Keys the field manager applied before and
+// that are missing from the patch are released, and removed unless another manager owns them
+func applyNSLabels(ctx context.Context, c client.Client, fieldManager string, namespace *v1.Namespace, labels map[string]string, annotations map[string]string) error {
 	log := log.FromContext(ctx)
-	log.Info("Updating namespace labels")
+	log.Info("Applying namespace labels", "fieldManager", fieldManager)
 
-	// add the namespace labels to match the request
-	if namespace.ObjectMeta.Labels == nil {
-		namespace.ObjectMeta.Labels = make(map[string]string)
+	patch := &unstructured.Unstructured{}
+	patch.SetAPIVersion("v1")
+	patch.SetKind("Namespace")
+	patch.SetName(namespace.Name)
+	patch.SetLabels(labels)
+	patch.SetAnnotations(annotations)
+
+	if err := c.Patch(ctx, patch, client.Apply, client.FieldOwner(fieldManager), client.ForceOwnership); err != nil {
+		log.Error(err, "failed to apply namespace labels")
+		return err
+	}
+
+	return nil
+}
+
+// this function applies labels and annotations under the retained field manager, so they stay on the
+// namespace once the source that set them is gone. The keys retained before are applied along with
+// them, since the apply would release them otherwise
+func retainNSLabels(ctx context.Context, c client.Client, namespace *v1.Namespace, labels map[string]string, annotations map[string]string) error {
+	retainedLabels, retainedAnnotations, ok := getAppliedKeys(namespace, retainedFieldManager)
+	if !ok {
+		return fmt.Errorf("unable to read the keys retained on namespace %s", namespace.Name)
+	}
+	for key, val := range labels {
+		retainedLabels[key] = val
 	}
+	for key, val := range annotations {
+		retainedAnnotations[key] = val
+	}
+
+	return applyNSLabels(ctx, c, retainedFieldManager, namespace, retainedLabels, retainedAnnotations)
+}
+
+// getAppliedKeys returns the labels and annotations of the namespace owned by the field manager through
+// server-side apply, ok is false when the managed fields of the namespace cannot be read
+func getAppliedKeys(namespace *v1.Namespace, fieldManager string) (map[string]string, map[string]string, bool) {
+	labels := make(map[string]string)
+	annotations := make(map[string]string)
+	for _, entry := range namespace.ManagedFields {
+		if entry.Manager != fieldManager || entry.Operation != metav1.ManagedFieldsOperationApply || entry.Subresource != "" || entry.FieldsV1 == nil {
+			continue
+		}
 
-	// first delete obsolete labels
-	for key := range delLabels {
-		delete(namespace.ObjectMeta.Labels, key)
+		fields := struct {
+			Metadata struct {
+				Labels      map[string]interface{} `json:"f:labels"`
+				Annotations map[string]interface{} `json:"f:annotations"`
+			} `json:"f:metadata"`
+		}{}
+		if err := json.Unmarshal(entry.FieldsV1.Raw, &fields); err != nil {
+			return nil, nil, false
+		}
+		for key := range fields.Metadata.Labels {
+			if val, ok := namespace.Labels[strings.TrimPrefix(key, "f:")]; ok && strings.HasPrefix(key, "f:") {
+				labels[strings.TrimPrefix(key, "f:")] = val
+			}
+		}
+		for key := range fields.Metadata.Annotations {
+			if val, ok := namespace.Annotations[strings.TrimPrefix(key, "f:")]; ok && strings.HasPrefix(key, "f:") {
+				annotations[strings.TrimPrefix(key, "f:")] = val
+			}
+		}
 	}
 
-	// then add the needed labels
-	for key, val := range addLabels {
-		namespace.ObjectMeta.Labels[key] = val
+	return labels, annotations, true
+}
+
+// this function sets labels and annotations back to the values they had before they were taken
+// over. It uses a merge patch so the restored keys are not owned by the field manager of any source
+func restoreNSLabels(ctx context.Context, c client.Client, namespace *v1.Namespace, labels map[string]string, annotations map[string]string) error {
+	log := log.FromContext(ctx)
+	log.Info("Restoring original namespace labels")
+
+	metadata := map[string]interface{}{}
+	if len(labels) > 0 {
+		metadata["labels"] = labels
+	}
+	if len(annotations) > 0 {
+		metadata["annotations"] = annotations
+	}
+	patch, err := json.Marshal(map[string]interface{}{"metadata": metadata})
+	if err != nil {
+		return err
 	}
 
-	// update the namespace with the new labels
-	if err := r.Update(ctx, namespace); err != nil {
-		log.Error(err, "failed to update namespace")
+	if err := c.Patch(ctx, namespace, client.RawPatch(types.MergePatchType, patch)); err != nil {
+		log.Error(err, "failed to restore namespace labels")
 		return err
 	}
 
 	return nil
 }
 
+// nilIfEmpty returns nil for an empty map so it is omitted from the status
+func nilIfEmpty(m map[string]string) map[string]string {
+	if len(m) == 0 {
+		return nil
+	}
+
+	return m
+}
+
+// namespaceLabelFieldManager returns the field manager used to apply the keys of a NamespaceLabel,
+// so the owner of every key is visible in the managedFields of the namespace
+func namespaceLabelFieldManager(namespaceLabel *danaiov1alpha1.NamespaceLabel) string {
+	fieldManager := fmt.Sprintf("namespacelabel/%s/%s", namespaceLabel.Namespace, namespaceLabel.Name)
+	if len(fieldManager) > maxFieldManagerLength {
+		return fmt.Sprintf("namespacelabel/%s", namespaceLabel.UID)
+	}
+
+	return fieldManager
+}
+
 // SetupWithManager sets up the controller with the Manager.
 func (r *NamespaceLabelReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&danaiov1alpha1.NamespaceLabel{}).
+		Watches(&source.Kind{Type: &v1.Namespace{}}, handler.EnqueueRequestsFromMapFunc(r.findNamespaceLabelsForNamespace)).
+		Watches(&source.Kind{Type: &danaiov1alpha1.NamespaceLabel{}}, handler.EnqueueRequestsFromMapFunc(r.findSiblingNamespaceLabels)).
+		Watches(&source.Kind{Type: &configv1alpha1.NamespacelabelConfig{}}, handler.EnqueueRequestsFromMapFunc(r.findNamespaceLabelsForConfig),
+			builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		// status changes of a ClusterNamespaceLabel come with writes to its namespaces, which are watched already
+		Watches(&source.Kind{Type: &danaiov1alpha1.ClusterNamespaceLabel{}}, handler.EnqueueRequestsFromMapFunc(r.findNamespaceLabelsForClusterNamespaceLabel),
+			builder.WithPredicates(predicate.GenerationChangedPredicate{})).
 		Complete(r)
 }
+
+// findNamespaceLabelsForNamespace maps a namespace event to reconcile requests
+// for every NamespaceLabel in that namespace, so that out-of-band changes to
+// managed labels on the namespace are reverted
+func (r *NamespaceLabelReconciler) findNamespaceLabelsForNamespace(namespace client.Object) []reconcile.Request {
+	namespaceLabels := &danaiov1alpha1.NamespaceLabelList{}
+	if err := r.List(context.TODO(), namespaceLabels, client.InNamespace(namespace.GetName())); err != nil {
+		return []reconcile.Request{}
+	}
+
+	requests := make([]reconcile.Request, len(namespaceLabels.Items))
+	for i, item := range namespaceLabels.Items {
+		requests[i] = reconcile.Request{
+			NamespacedName: types.NamespacedName{
+				Name:      item.GetName(),
+				Namespace: item.GetNamespace(),
+			},
+		}
+	}
+
+	return requests
+}
+
+// findSiblingNamespaceLabels maps a NamespaceLabel event to reconcile requests for
+// every NamespaceLabel in the same namespace, since a change in the keys or priority
+// of one NamespaceLabel may change which keys the others own
+func (r *NamespaceLabelReconciler) findSiblingNamespaceLabels(namespaceLabel client.Object) []reconcile.Request {
+	namespaceLabels := &danaiov1alpha1.NamespaceLabelList{}
+	if err := r.List(context.TODO(), namespaceLabels, client.InNamespace(namespaceLabel.GetNamespace())); err != nil {
+		return []reconcile.Request{}
+	}
+
+	requests := []reconcile.Request{}
+	for _, item := range namespaceLabels.Items {
+		if item.GetName() == namespaceLabel.GetName() {
+			continue
+		}
+		requests = append(requests, reconcile.Request{
+			NamespacedName: types.NamespacedName{
+				Name:      item.GetName(),
+				Namespace: item.GetNamespace(),
+			},
+		})
+	}
+
+	return requests
+}
+
+// findNamespaceLabelsForConfig maps a cluster policy event to reconcile requests for
+// every NamespaceLabel in the cluster, so that cluster-wide changes are applied live
+func (r *NamespaceLabelReconciler) findNamespaceLabelsForConfig(config client.Object) []reconcile.Request {
+	namespaceLabels := &danaiov1alpha1.NamespaceLabelList{}
+	if err := r.List(context.TODO(), namespaceLabels); err != nil {
+		return []reconcile.Request{}
+	}
+
+	requests := make([]reconcile.Request, len(namespaceLabels.Items))
+	for i, item := range namespaceLabels.Items {
+		requests[i] = reconcile.Request{
+			NamespacedName: types.NamespacedName{
+				Name:      item.GetName(),
+				Namespace: item.GetNamespace(),
+			},
+		}
+	}
+
+	return requests
+}
+
+// findNamespaceLabelsForClusterNamespaceLabel maps a ClusterNamespaceLabel event to reconcile
+// requests for the NamespaceLabels in the namespaces it selects or has labeled
+func (r *NamespaceLabelReconciler) findNamespaceLabelsForClusterNamespaceLabel(clusterNamespaceLabel client.Object) []reconcile.Request {
+	names, err := getClusterNamespaceLabelNamespaces(context.TODO(), r.Client, clusterNamespaceLabel.(*danaiov1alpha1.ClusterNamespaceLabel))
+	if err != nil {
+		return []reconcile.Request{}
+	}
+
+	requests := []reconcile.Request{}
+	for _, name := range names {
+		namespaceLabels := &danaiov1alpha1.NamespaceLabelList{}
+		if err := r.List(context.TODO(), namespaceLabels, client.InNamespace(name)); err != nil {
+			return []reconcile.Request{}
+		}
+		for _, item := range namespaceLabels.Items {
+			requests = append(requests, reconcile.Request{
+				NamespacedName: types.NamespacedName{
+					Name:      item.GetName(),
+					Namespace: item.GetNamespace(),
+				},
+			})
+		}
+	}
+
+	return requests
+}
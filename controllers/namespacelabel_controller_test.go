@@ -18,27 +18,38 @@ package controllers
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/pointer"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
+	configv1alpha1 "home-assignment/apis/config/v1alpha1"
 	danaiov1alpha1 "home-assignment/apis/namespacelabel/v1alpha1"
 )
 
 const (
-	LabelKey = "label-key"
-	LabelVal = "label-value"
+	LabelKey      = "label-key"
+	LabelVal      = "label-value"
+	AnnotationKey = "annotation-key"
+	AnnotationVal = "annotation-value"
 )
 
 func setupClient(obj []client.Object) (client.Client, *runtime.Scheme, error) {
@@ -47,12 +58,235 @@ func setupClient(obj []client.Object) (client.Client, *runtime.Scheme, error) {
 	if err := danaiov1alpha1.AddToScheme(s); err != nil {
 		return nil, s, err
 	}
+	if err := configv1alpha1.AddToScheme(s); err != nil {
+		return nil, s, err
+	}
 
 	// create fake client
 	cl := fake.NewClientBuilder().WithObjects(obj...).Build()
 
-	return cl, s, nil
+	return &fakeApplyClient{Client: cl, applied: map[string]appliedKeys{}}, s, nil
+
+}
+
+// appliedKeys holds the labels and annotations a field manager applied to a namespace
+type appliedKeys struct {
+	labels      map[string]string
+	annotations map[string]string
+}
+
+// fakeApplyClient emulates server-side apply of namespace labels and annotations,
+// since the fake client does not support apply patches. Keys a field manager applied
+// before and that are missing from its next apply are removed from the namespace, unless
+// another field manager applied the same value or a merge patch changed them since,
+// which is how the API server tracks ownership
+type fakeApplyClient struct {
+	client.Client
+	applied map[string]appliedKeys
+	// keys changed by merge patches, by namespace
+	patched map[string]appliedKeys
+	// errors returned by the applies of a field manager
+	applyErrs map[string]error
+}
+
+func (c *fakeApplyClient) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+	if patch.Type() == types.MergePatchType {
+		return c.mergePatch(ctx, obj, patch, opts...)
+	}
+	if patch.Type() != types.ApplyPatchType {
+		return c.Client.Patch(ctx, obj, patch, opts...)
+	}
+
+	patchOpts := &client.PatchOptions{}
+	patchOpts.ApplyOptions(opts)
+	key := patchOpts.FieldManager + "/" + obj.GetName()
+	if err := c.applyErrs[patchOpts.FieldManager]; err != nil {
+		return err
+	}
+
+	// look the namespace up by name, the fixtures may carry a namespace in their metadata
+	namespaces := &v1.NamespaceList{}
+	if err := c.List(ctx, namespaces); err != nil {
+		return err
+	}
+	var namespace *v1.Namespace
+	for i := range namespaces.Items {
+		if namespaces.Items[i].Name == obj.GetName() {
+			namespace = &namespaces.Items[i]
+		}
+	}
+	if namespace == nil {
+		return errors.NewNotFound(v1.Resource("namespaces"), obj.GetName())
+	}
+	if namespace.Labels == nil {
+		namespace.Labels = map[string]string{}
+	}
+	if namespace.Annotations == nil {
+		namespace.Annotations = map[string]string{}
+	}
+
+	// release the keys applied before, then set the applied ones
+	patched := c.patched[obj.GetName()]
+	for k := range c.applied[key].labels {
+		if _, ok := patched.labels[k]; !ok && !c.appliedByOthers(key, obj.GetName(), k, true) {
+			delete(namespace.Labels, k)
+		}
+	}
+	for k := range c.applied[key].annotations {
+		if _, ok := patched.annotations[k]; !ok && !c.appliedByOthers(key, obj.GetName(), k, false) {
+			delete(namespace.Annotations, k)
+		}
+	}
+	for k, v := range obj.GetLabels() {
+		namespace.Labels[k] = v
+	}
+	for k, v := range obj.GetAnnotations() {
+		namespace.Annotations[k] = v
+	}
+	// a forced apply takes the keys it changes over from every other field manager
+	c.takeOver(key, obj.GetName(), obj.GetLabels(), obj.GetAnnotations())
+	c.applied[key] = appliedKeys{labels: obj.GetLabels(), annotations: obj.GetAnnotations()}
+	if err := setAppliedFields(namespace, patchOpts.FieldManager, obj.GetLabels(), obj.GetAnnotations()); err != nil {
+		return err
+	}
+
+	return c.Update(ctx, namespace)
+}
+
+// setAppliedFields records the keys applied by the field manager in the managed fields of the namespace
+func setAppliedFields(namespace *v1.Namespace, fieldManager string, labels map[string]string, annotations map[string]string) error {
+	metadata := map[string]interface{}{}
+	for name, keys := range map[string]map[string]string{"f:labels": labels, "f:annotations": annotations} {
+		fields := map[string]interface{}{}
+		for k := range keys {
+			fields["f:"+k] = map[string]interface{}{}
+		}
+		metadata[name] = fields
+	}
+	raw, err := json.Marshal(map[string]interface{}{"f:metadata": metadata})
+	if err != nil {
+		return err
+	}
+
+	entry := metav1.ManagedFieldsEntry{
+		Manager:    fieldManager,
+		Operation:  metav1.ManagedFieldsOperationApply,
+		FieldsType: "FieldsV1",
+		FieldsV1:   &metav1.FieldsV1{Raw: raw},
+	}
+	for i := range namespace.ManagedFields {
+		if namespace.ManagedFields[i].Manager == fieldManager && namespace.ManagedFields[i].Operation == metav1.ManagedFieldsOperationApply {
+			namespace.ManagedFields[i] = entry
+			return nil
+		}
+	}
+	namespace.ManagedFields = append(namespace.ManagedFields, entry)
+
+	return nil
+}
+
+// appliedByOthers reports whether a field manager other than the one of the key applied the label
+// or annotation to the namespace
+func (c *fakeApplyClient) appliedByOthers(key string, name string, k string, isLabel bool) bool {
+	for other, applied := range c.applied {
+		if other == key || !strings.HasSuffix(other, "/"+name) {
+			continue
+		}
+		keys := applied.annotations
+		if isLabel {
+			keys = applied.labels
+		}
+		if _, ok := keys[k]; ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// takeOver removes the labels and annotations written with a different value from the keys
+// owned by the other field managers of the namespace, leaving the same values co-owned
+func (c *fakeApplyClient) takeOver(key string, name string, labels map[string]string, annotations map[string]string) {
+	for other, applied := range c.applied {
+		if other == key || !strings.HasSuffix(other, "/"+name) {
+			continue
+		}
+		for k, v := range labels {
+			if val, ok := applied.labels[k]; ok && val != v {
+				delete(applied.labels, k)
+			}
+		}
+		for k, v := range annotations {
+			if val, ok := applied.annotations[k]; ok && val != v {
+				delete(applied.annotations, k)
+			}
+		}
+	}
+	if patched, ok := c.patched[name]; ok {
+		for k, v := range labels {
+			if val, ok := patched.labels[k]; ok && val != v {
+				delete(patched.labels, k)
+			}
+		}
+		for k, v := range annotations {
+			if val, ok := patched.annotations[k]; ok && val != v {
+				delete(patched.annotations, k)
+			}
+		}
+	}
+}
+
+// mergePatch records the keys a merge patch changes on a namespace, like an update it only
+// takes over the keys whose value it changes and leaves keys written with their current value alone
+func (c *fakeApplyClient) mergePatch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+	namespace, ok := obj.(*v1.Namespace)
+	if !ok {
+		return c.Client.Patch(ctx, obj, patch, opts...)
+	}
+
+	data, err := patch.Data(obj)
+	if err != nil {
+		return err
+	}
+	written := &v1.Namespace{}
+	if err := json.Unmarshal(data, written); err != nil {
+		return err
+	}
+	current := &v1.Namespace{}
+	if err := c.Get(ctx, client.ObjectKeyFromObject(namespace), current); err != nil {
+		return err
+	}
+
+	if c.patched == nil {
+		c.patched = map[string]appliedKeys{}
+	}
+	patched := c.patched[obj.GetName()]
+	if patched.labels == nil {
+		patched = appliedKeys{labels: map[string]string{}, annotations: map[string]string{}}
+	}
+	changedLabels := map[string]string{}
+	for k, v := range written.Labels {
+		if val, ok := current.Labels[k]; !ok || val != v {
+			changedLabels[k] = v
+			patched.labels[k] = v
+		}
+	}
+	changedAnnotations := map[string]string{}
+	for k, v := range written.Annotations {
+		if val, ok := current.Annotations[k]; !ok || val != v {
+			changedAnnotations[k] = v
+			patched.annotations[k] = v
+		}
+	}
+	c.patched[obj.GetName()] = patched
+	c.takeOver("", obj.GetName(), changedLabels, changedAnnotations)
+
+	return c.Client.Patch(ctx, obj, patch, opts...)
+}
 
+// seedAppliedKeys records keys as applied to a namespace by a field manager
+func seedAppliedKeys(cl client.Client, fieldManager string, name string, labels map[string]string, annotations map[string]string) {
+	cl.(*fakeApplyClient).applied[fieldManager+"/"+name] = appliedKeys{labels: copyKeys(labels), annotations: copyKeys(annotations)}
 }
 
 func generateNamespacelabelObject() *danaiov1alpha1.NamespaceLabel {
@@ -65,11 +299,17 @@ func generateNamespacelabelObject() *danaiov1alpha1.NamespaceLabel {
 			Labels: map[string]string{
 				LabelKey: LabelVal,
 			},
+			Annotations: map[string]string{
+				AnnotationKey: AnnotationVal,
+			},
 		},
 		Status: danaiov1alpha1.NamespaceLabelStatus{
 			ActiveLabels: map[string]string{
 				LabelKey: LabelVal,
 			},
+			ActiveAnnotations: map[string]string{
+				AnnotationKey: AnnotationVal,
+			},
 		},
 	}
 
@@ -85,69 +325,240 @@ func generateNamespaceObject() *v1.Namespace {
 				"kubernetes.io/name": "default",
 				LabelKey:             LabelVal,
 			},
+			Annotations: map[string]string{
+				"openshift.io/description": "default",
+				AnnotationKey:              AnnotationVal,
+			},
 		},
 	}
 
 	return namespace
 }
 
-func TestDeleteLabels(t *testing.T) {
+func TestNamespaceLabelFieldManager(t *testing.T) {
+	g := NewGomegaWithT(t)
+	RegisterFailHandler(ginkgo.Fail)
+
+	namespaceLabel := generateNamespacelabelObject()
+	namespaceLabel.UID = "0a1b2c3d"
+
+	// run function to test
+	g.Expect(namespaceLabelFieldManager(namespaceLabel)).To(Equal("namespacelabel/default/namespacelabel-test"))
+
+	// fall back to the uid when the name does not fit in a field manager
+	namespaceLabel.Name = strings.Repeat("a", maxFieldManagerLength)
+	g.Expect(namespaceLabelFieldManager(namespaceLabel)).To(Equal("namespacelabel/0a1b2c3d"))
+}
+
+func TestDeleteFinalizer(t *testing.T) {
 	g := NewGomegaWithT(t)
 	RegisterFailHandler(ginkgo.Fail)
 
 	namespaceLabel := generateNamespacelabelObject()
 	namespace := generateNamespaceObject()
 
-	// objects to track in the fake client
 	obj := []client.Object{namespaceLabel, namespace}
-
 	cl, s, err := setupClient(obj)
 	if err != nil {
 		t.Fatalf("Unable to add to scheme: %v", err)
 	}
 
 	// create a NamespaceLabelReconciler object with the scheme and fake client
-	r := &NamespaceLabelReconciler{cl, s}
+	r := &NamespaceLabelReconciler{cl, s, record.NewFakeRecorder(10)}
+
+	// add finalizer to namespacelabel object
+	controllerutil.AddFinalizer(namespaceLabel, NamespaceLabelFinalizer)
+
+	// the active keys were applied by the namespacelabel before
+	seedAppliedKeys(cl, namespaceLabelFieldManager(namespaceLabel), namespace.Name, namespaceLabel.Status.ActiveLabels, namespaceLabel.Status.ActiveAnnotations)
 
 	// run function to test
-	r.deleteLabels(namespaceLabel, namespace)
+	if err := r.deleteFinalizer(context.TODO(), namespaceLabel, namespace); err != nil {
+		t.Fatalf("Unable to delete finalizer: %v", err)
+	}
 
 	// set expected result and check result matches expected
 	g.Expect(func() bool {
-		expectedLabels := map[string]string{
-			"kubernetes.io/name": "default",
-		}
-		actualLabels := namespace.ObjectMeta.Labels
-		return reflect.DeepEqual(expectedLabels, actualLabels)
+		return !controllerutil.ContainsFinalizer(namespaceLabel, NamespaceLabelFinalizer)
 	}()).To(BeTrue())
+
+	// check that the applied keys were released from the namespace
+	if err := r.Get(context.TODO(), client.ObjectKeyFromObject(namespace), namespace); err != nil {
+		t.Fatalf("get: (%v)", err)
+	}
+	g.Expect(namespace.Labels).To(Equal(map[string]string{"kubernetes.io/name": "default"}))
+	g.Expect(namespace.Annotations).To(Equal(map[string]string{"openshift.io/description": "default"}))
 }
 
-func TestDeleteFinalizer(t *testing.T) {
+func TestDeleteFinalizerDeletionPolicy(t *testing.T) {
 	g := NewGomegaWithT(t)
 	RegisterFailHandler(ginkgo.Fail)
 
+	// the label is retained by the policy of the object, the annotation was taken over and
+	// is restored by its own policy, and the zone label is deleted despite its original value
 	namespaceLabel := generateNamespacelabelObject()
+	namespaceLabel.Spec.Labels["zone"] = "internal"
+	namespaceLabel.Status.ActiveLabels["zone"] = "internal"
+	namespaceLabel.Status.OriginalLabels = map[string]string{"zone": "public"}
+	namespaceLabel.Status.OriginalAnnotations = map[string]string{AnnotationKey: "original"}
+	namespaceLabel.Spec.DeletionPolicy = danaiov1alpha1.DeletionPolicyRetain
+	namespaceLabel.Spec.KeyDeletionPolicies = []danaiov1alpha1.KeyDeletionPolicy{
+		{Key: AnnotationKey, Policy: danaiov1alpha1.DeletionPolicyRestoreOriginal},
+		{Key: "zone", Policy: danaiov1alpha1.DeletionPolicyDelete},
+	}
+	controllerutil.AddFinalizer(namespaceLabel, NamespaceLabelFinalizer)
 	namespace := generateNamespaceObject()
+	namespace.Labels["zone"] = "internal"
 
-	obj := []client.Object{namespaceLabel, namespace}
-	cl, s, err := setupClient(obj)
+	cl, s, err := setupClient([]client.Object{namespaceLabel, namespace})
 	if err != nil {
 		t.Fatalf("Unable to add to scheme: %v", err)
 	}
 
 	// create a NamespaceLabelReconciler object with the scheme and fake client
-	r := &NamespaceLabelReconciler{cl, s}
+	r := &NamespaceLabelReconciler{cl, s, record.NewFakeRecorder(10)}
 
-	// add finalizer to namespacelabel object
+	// the active keys were applied by the namespacelabel before
+	seedAppliedKeys(cl, namespaceLabelFieldManager(namespaceLabel), namespace.Name, namespaceLabel.Status.ActiveLabels, namespaceLabel.Status.ActiveAnnotations)
+
+	if err := r.deleteFinalizer(context.TODO(), namespaceLabel, namespace); err != nil {
+		t.Fatalf("Unable to delete finalizer: %v", err)
+	}
+
+	// check that every key was handled according to its deletion policy, the retained label
+	// keeps the value it already has on the namespace
+	if err := r.Get(context.TODO(), client.ObjectKeyFromObject(namespace), namespace); err != nil {
+		t.Fatalf("get: (%v)", err)
+	}
+	g.Expect(namespace.Labels).To(Equal(map[string]string{"kubernetes.io/name": "default", LabelKey: LabelVal}))
+	g.Expect(namespace.Annotations).To(Equal(map[string]string{"openshift.io/description": "default", AnnotationKey: "original"}))
+}
+
+func TestDeleteFinalizerRetainKeepsRetainedKeys(t *testing.T) {
+	g := NewGomegaWithT(t)
+	RegisterFailHandler(ginkgo.Fail)
+
+	first := generateNamespacelabelObject()
+	first.Spec.DeletionPolicy = danaiov1alpha1.DeletionPolicyRetain
+	first.Status.ActiveAnnotations = nil
+	controllerutil.AddFinalizer(first, NamespaceLabelFinalizer)
+	second := generateNamespacelabelObject()
+	second.Name = "namespacelabel-second"
+	second.Spec.Labels = map[string]string{"team": "a"}
+	second.Spec.DeletionPolicy = danaiov1alpha1.DeletionPolicyRetain
+	second.Status.ActiveLabels = map[string]string{"team": "a"}
+	second.Status.ActiveAnnotations = nil
+	controllerutil.AddFinalizer(second, NamespaceLabelFinalizer)
+	namespace := generateNamespaceObject()
+	namespace.Labels["team"] = "a"
+
+	cl, s, err := setupClient([]client.Object{first, second, namespace})
+	if err != nil {
+		t.Fatalf("Unable to add to scheme: %v", err)
+	}
+	seedAppliedKeys(cl, namespaceLabelFieldManager(first), namespace.Name, first.Status.ActiveLabels, nil)
+	seedAppliedKeys(cl, namespaceLabelFieldManager(second), namespace.Name, second.Status.ActiveLabels, nil)
+
+	// create a NamespaceLabelReconciler object with the scheme and fake client
+	r := &NamespaceLabelReconciler{cl, s, record.NewFakeRecorder(10)}
+
+	// check that the keys retained by the first deletion survive the second one
+	for _, namespaceLabel := range []*danaiov1alpha1.NamespaceLabel{first, second} {
+		if err := r.Get(context.TODO(), client.ObjectKeyFromObject(namespace), namespace); err != nil {
+			t.Fatalf("get: (%v)", err)
+		}
+		if err := r.deleteFinalizer(context.TODO(), namespaceLabel, namespace); err != nil {
+			t.Fatalf("Unable to delete finalizer: %v", err)
+		}
+	}
+	if err := r.Get(context.TODO(), client.ObjectKeyFromObject(namespace), namespace); err != nil {
+		t.Fatalf("get: (%v)", err)
+	}
+	g.Expect(namespace.Labels).To(Equal(map[string]string{"kubernetes.io/name": "default", LabelKey: LabelVal, "team": "a"}))
+}
+
+func TestDeleteFinalizerRetainApplyFails(t *testing.T) {
+	g := NewGomegaWithT(t)
+	RegisterFailHandler(ginkgo.Fail)
+
+	namespaceLabel := generateNamespacelabelObject()
+	namespaceLabel.Spec.DeletionPolicy = danaiov1alpha1.DeletionPolicyRetain
 	controllerutil.AddFinalizer(namespaceLabel, NamespaceLabelFinalizer)
+	namespace := generateNamespaceObject()
 
-	// run function to test
-	r.deleteFinalizer(context.TODO(), namespaceLabel, namespace)
+	cl, s, err := setupClient([]client.Object{namespaceLabel, namespace})
+	if err != nil {
+		t.Fatalf("Unable to add to scheme: %v", err)
+	}
+	seedAppliedKeys(cl, namespaceLabelFieldManager(namespaceLabel), namespace.Name, namespaceLabel.Status.ActiveLabels, namespaceLabel.Status.ActiveAnnotations)
+	cl.(*fakeApplyClient).applyErrs = map[string]error{retainedFieldManager: fmt.Errorf("apply failed")}
 
-	// set expected result and check result matches expected
-	g.Expect(func() bool {
-		return !controllerutil.ContainsFinalizer(namespaceLabel, NamespaceLabelFinalizer)
-	}()).To(BeTrue())
+	// create a NamespaceLabelReconciler object with the scheme and fake client
+	r := &NamespaceLabelReconciler{cl, s, record.NewFakeRecorder(10)}
+
+	// check that the retained keys are not released when they could not be written
+	g.Expect(r.deleteFinalizer(context.TODO(), namespaceLabel, namespace)).NotTo(Succeed())
+	g.Expect(controllerutil.ContainsFinalizer(namespaceLabel, NamespaceLabelFinalizer)).To(BeTrue())
+	if err := r.Get(context.TODO(), client.ObjectKeyFromObject(namespace), namespace); err != nil {
+		t.Fatalf("get: (%v)", err)
+	}
+	g.Expect(namespace.Labels).To(HaveKeyWithValue(LabelKey, LabelVal))
+	g.Expect(namespace.Annotations).To(HaveKeyWithValue(AnnotationKey, AnnotationVal))
+}
+
+func TestReconcilerNamespaceLifecycle(t *testing.T) {
+	g := NewGomegaWithT(t)
+	RegisterFailHandler(ginkgo.Fail)
+
+	now := metav1.Now()
+	tests := map[string]struct {
+		namespace *v1.Namespace
+		reason    string
+	}{
+		"missing": {nil, ReasonNamespaceNotFound},
+		"terminating": {func() *v1.Namespace {
+			namespace := generateNamespaceObject()
+			namespace.DeletionTimestamp = &now
+			namespace.Finalizers = []string{"kubernetes"}
+			return namespace
+		}(), ReasonNamespaceTerminating},
+	}
+
+	for name, test := range tests {
+		namespaceLabel := generateNamespacelabelObject()
+		namespaceLabel.DeletionTimestamp = &now
+		controllerutil.AddFinalizer(namespaceLabel, NamespaceLabelFinalizer)
+
+		obj := []client.Object{namespaceLabel}
+		if test.namespace != nil {
+			obj = append(obj, test.namespace)
+		}
+		cl, s, err := setupClient(obj)
+		if err != nil {
+			t.Fatalf("Unable to add to scheme: %v", err)
+		}
+
+		recorder := record.NewFakeRecorder(10)
+		r := &NamespaceLabelReconciler{cl, s, recorder}
+
+		if _, err := r.Reconcile(context.TODO(), reconcile.Request{NamespacedName: client.ObjectKeyFromObject(namespaceLabel)}); err != nil {
+			t.Fatalf("%s: unable to reconcile: %v", name, err)
+		}
+
+		// check that the finalizer is removed, which lets the deletion complete, and the reason is recorded
+		err = r.Get(context.TODO(), client.ObjectKeyFromObject(namespaceLabel), namespaceLabel)
+		g.Expect(errors.IsNotFound(err)).To(BeTrue(), name)
+		g.Expect(recorder.Events).To(Receive(ContainSubstring(test.reason)), name)
+
+		// check that the keys were not written to the terminating namespace
+		if test.namespace != nil {
+			namespace := &v1.Namespace{}
+			if err := r.Get(context.TODO(), client.ObjectKeyFromObject(test.namespace), namespace); err != nil {
+				t.Fatalf("get: (%v)", err)
+			}
+			g.Expect(namespace.Labels).To(Equal(test.namespace.Labels), name)
+		}
+	}
 }
 
 func TestAddFinalizer(t *testing.T) {
@@ -163,7 +574,7 @@ func TestAddFinalizer(t *testing.T) {
 	}
 
 	// create a NamespaceLabelReconciler object with the scheme and fake client
-	r := &NamespaceLabelReconciler{cl, s}
+	r := &NamespaceLabelReconciler{cl, s, record.NewFakeRecorder(10)}
 
 	// run function to test
 	r.addFinalizer(context.TODO(), namespaceLabel)
@@ -195,17 +606,8 @@ func TestGetNamespaceLabelsDiffs(t *testing.T) {
 	namespaceLabel.Spec.Labels = newSpecLabels
 	namespaceLabel.Status.ActiveLabels = newStatusLabels
 
-	obj := []client.Object{namespaceLabel}
-	cl, s, err := setupClient(obj)
-	if err != nil {
-		t.Fatalf("Unable to add to scheme: %v", err)
-	}
-
-	// create a NamespaceLabelReconciler object with the scheme and fake client
-	r := &NamespaceLabelReconciler{cl, s}
-
 	// run function to test
-	addLabels, delLabels := r.getNamespaceLabelsDiffs(namespaceLabel)
+	addLabels, delLabels := getNamespaceLabelsDiffs(namespaceLabel.Spec.Labels, namespaceLabel.Status.ActiveLabels)
 
 	// set expected result and check result matches expected
 	g.Expect(func() bool {
@@ -221,96 +623,922 @@ func TestGetNamespaceLabelsDiffs(t *testing.T) {
 	}()).To(BeTrue())
 }
 
-func TestUpdateNSLabels(t *testing.T) {
+func TestGetAllowedLabels(t *testing.T) {
 	g := NewGomegaWithT(t)
 	RegisterFailHandler(ginkgo.Fail)
 
-	namespace := generateNamespaceObject()
-
-	addLabels := map[string]string{
-		"labelB": "testlabelB",
-		"labelC": "testlabelC2",
-	}
-	delLabels := map[string]string{
-		"labelD": "testlabelD",
+	config := &configv1alpha1.NamespacelabelConfig{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: configv1alpha1.ClusterConfigName,
+		},
+		Spec: configv1alpha1.NamespacelabelConfigSpec{
+			ProtectedDomains: []string{"kubernetes.io"},
+		},
 	}
 
-	obj := []client.Object{namespace}
+	obj := []client.Object{config}
 	cl, s, err := setupClient(obj)
 	if err != nil {
 		t.Fatalf("Unable to add to scheme: %v", err)
 	}
 
 	// create a NamespaceLabelReconciler object with the scheme and fake client
-	r := &NamespaceLabelReconciler{cl, s}
+	r := &NamespaceLabelReconciler{cl, s, record.NewFakeRecorder(10)}
 
-	// run function to test
-	if err := r.updateNSLabels(context.TODO(), namespace, addLabels, delLabels); err != nil {
-		t.Fatalf("Unable to add to update NS Labels: %v", err)
+	labels := map[string]string{
+		"kubernetes.io/metadata.name": "test",
+		LabelKey:                      LabelVal,
 	}
 
+	// run function to test
+	allowedLabels, rejectedLabels := r.getAllowedLabels(context.TODO(), config, labels)
+
 	// set expected result and check result matches expected
 	g.Expect(func() bool {
 		expectedLabels := map[string]string{
-			"kubernetes.io/name": "default",
-			LabelKey:             LabelVal,
-			"labelB":             "testlabelB",
-			"labelC":             "testlabelC2",
+			LabelKey: LabelVal,
 		}
 
-		return reflect.DeepEqual(namespace.ObjectMeta.Labels, expectedLabels)
+		_, rejected := rejectedLabels["kubernetes.io/metadata.name"]
+		return reflect.DeepEqual(allowedLabels, expectedLabels) && rejected && len(rejectedLabels) == 1
 	}()).To(BeTrue())
 }
 
-func TestReconciler(t *testing.T) {
+func TestGetOwnedLabels(t *testing.T) {
 	g := NewGomegaWithT(t)
 	RegisterFailHandler(ginkgo.Fail)
 
 	namespaceLabel := generateNamespacelabelObject()
-	namespace := generateNamespaceObject()
+	namespaceLabel.ObjectMeta.UID = "uid-a"
+	namespaceLabel.ObjectMeta.CreationTimestamp = metav1.Unix(100, 0)
+	namespaceLabel.Spec.Labels = map[string]string{
+		"labelA": "testlabelA",
+		"labelB": "testlabelB",
+		"labelC": "testlabelC",
+	}
 
-	obj := []client.Object{namespaceLabel, namespace}
+	// higher priority object owns labelA
+	higherPriority := generateNamespacelabelObject()
+	higherPriority.ObjectMeta.Name = "higher-priority"
+	higherPriority.ObjectMeta.UID = "uid-b"
+	higherPriority.ObjectMeta.CreationTimestamp = metav1.Unix(200, 0)
+	higherPriority.Spec.Priority = 10
+	higherPriority.Spec.Labels = map[string]string{
+		"labelA": "other",
+	}
 
-	newStatusLabels := map[string]string{}
-	namespaceLabel.Status.ActiveLabels = newStatusLabels
+	// older object with the same priority owns labelB
+	older := generateNamespacelabelObject()
+	older.ObjectMeta.Name = "older"
+	older.ObjectMeta.UID = "uid-c"
+	older.ObjectMeta.CreationTimestamp = metav1.Unix(50, 0)
+	older.Spec.Labels = map[string]string{
+		"labelB": "other",
+	}
 
+	// newer object with the same priority loses labelC
+	newer := generateNamespacelabelObject()
+	newer.ObjectMeta.Name = "newer"
+	newer.ObjectMeta.UID = "uid-d"
+	newer.ObjectMeta.CreationTimestamp = metav1.Unix(300, 0)
+	newer.Spec.Labels = map[string]string{
+		"labelC": "other",
+	}
+
+	others := []danaiov1alpha1.NamespaceLabel{*namespaceLabel, *higherPriority, *older, *newer}
+
+	obj := []client.Object{namespaceLabel}
 	cl, s, err := setupClient(obj)
 	if err != nil {
 		t.Fatalf("Unable to add to scheme: %v", err)
 	}
 
 	// create a NamespaceLabelReconciler object with the scheme and fake client
-	r := &NamespaceLabelReconciler{cl, s}
+	r := &NamespaceLabelReconciler{cl, s, record.NewFakeRecorder(10)}
 
-	// mock request to simulate Reconcile() being called on an event for a watched resource
-	req := reconcile.Request{
-		NamespacedName: types.NamespacedName{
-			Name:      namespaceLabel.ObjectMeta.Name,
-			Namespace: namespaceLabel.ObjectMeta.Namespace,
-		},
-	}
+	// run function to test
+	ownedLabels, conflictLabels := r.getOwnedLabels(namespaceLabel, others, namespaceLabel.Spec.Labels, specLabels)
 
-	res, err := r.Reconcile(context.TODO(), req)
-	if err != nil {
-		t.Fatalf("Unable to reconcile: %v", err)
-	}
+	// set expected result and check result matches expected
+	g.Expect(ownedLabels).To(Equal(map[string]string{
+		"labelC": "testlabelC",
+	}))
+	g.Expect(conflictLabels).To(Equal(map[string]string{
+		"labelA": "key is owned by NamespaceLabel higher-priority",
+		"labelB": "key is owned by NamespaceLabel older",
+	}))
+}
 
-	// check the result of reconciliation to make sure it has the desired state
-	if res.Requeue {
-		t.Error("reconcile did not requeue request as expected")
+func TestGetNamespaceLabelsDrift(t *testing.T) {
+	g := NewGomegaWithT(t)
+	RegisterFailHandler(ginkgo.Fail)
+
+	namespaceLabel := generateNamespacelabelObject()
+	namespace := generateNamespaceObject()
+
+	namespaceLabel.Spec.Labels = map[string]string{
+		"labelA": "testlabelA",
+		"labelB": "testlabelB",
+		"labelC": "testlabelC2",
+	}
+	namespaceLabel.Status.ActiveLabels = map[string]string{
+		"labelA": "testlabelA",
+		"labelB": "testlabelB",
+		"labelC": "testlabelC",
 	}
 
-	err = r.Get(context.TODO(), req.NamespacedName, namespaceLabel)
-	if err != nil {
-		t.Fatalf("get: (%v)", err)
+	// labelA was removed and labelB was changed out-of-band
+	namespace.ObjectMeta.Labels = map[string]string{
+		"labelB": "changed",
+		"labelC": "testlabelC",
 	}
 
-	// check if the status matches the expected status
+	// run function to test
+	driftLabels := getNamespaceLabelsDrift(namespaceLabel.Spec.Labels, namespaceLabel.Status.ActiveLabels, namespace.ObjectMeta.Labels)
+
+	// set expected result and check result matches expected
 	g.Expect(func() bool {
-		expectedStatus := map[string]string{
-			LabelKey: LabelVal,
+		expectedDriftLabels := map[string]string{
+			"labelA": "testlabelA",
+			"labelB": "testlabelB",
 		}
 
-		return reflect.DeepEqual(namespaceLabel.Status.ActiveLabels, expectedStatus)
+		return reflect.DeepEqual(driftLabels, expectedDriftLabels)
 	}()).To(BeTrue())
 }
+
+func TestFindNamespaceLabelsForNamespace(t *testing.T) {
+	g := NewGomegaWithT(t)
+	RegisterFailHandler(ginkgo.Fail)
+
+	namespaceLabel := generateNamespacelabelObject()
+	namespace := generateNamespaceObject()
+
+	obj := []client.Object{namespaceLabel, namespace}
+	cl, s, err := setupClient(obj)
+	if err != nil {
+		t.Fatalf("Unable to add to scheme: %v", err)
+	}
+
+	// create a NamespaceLabelReconciler object with the scheme and fake client
+	r := &NamespaceLabelReconciler{cl, s, record.NewFakeRecorder(10)}
+
+	// run function to test
+	requests := r.findNamespaceLabelsForNamespace(namespace)
+
+	// set expected result and check result matches expected
+	g.Expect(func() bool {
+		expectedRequests := []reconcile.Request{
+			{
+				NamespacedName: types.NamespacedName{
+					Name:      namespaceLabel.ObjectMeta.Name,
+					Namespace: namespaceLabel.ObjectMeta.Namespace,
+				},
+			},
+		}
+
+		return reflect.DeepEqual(requests, expectedRequests)
+	}()).To(BeTrue())
+}
+
+func TestFindNamespaceLabelsForClusterNamespaceLabel(t *testing.T) {
+	g := NewGomegaWithT(t)
+	RegisterFailHandler(ginkgo.Fail)
+
+	namespaceLabel := generateNamespacelabelObject()
+	namespace := generateNamespaceObject()
+	other := &v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "other"}}
+	otherNamespaceLabel := &danaiov1alpha1.NamespaceLabel{
+		ObjectMeta: metav1.ObjectMeta{Name: "other-labels", Namespace: "other"},
+	}
+	clusterNamespaceLabel := &danaiov1alpha1.ClusterNamespaceLabel{
+		ObjectMeta: metav1.ObjectMeta{Name: "selected"},
+		Spec: danaiov1alpha1.ClusterNamespaceLabelSpec{
+			NamespaceSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"kubernetes.io/name": "default"}},
+		},
+	}
+
+	obj := []client.Object{namespaceLabel, namespace, other, otherNamespaceLabel, clusterNamespaceLabel}
+	cl, s, err := setupClient(obj)
+	if err != nil {
+		t.Fatalf("Unable to add to scheme: %v", err)
+	}
+
+	// create a NamespaceLabelReconciler object with the scheme and fake client
+	r := &NamespaceLabelReconciler{cl, s, record.NewFakeRecorder(10)}
+
+	// check that only the NamespaceLabels of the selected namespace are enqueued
+	g.Expect(r.findNamespaceLabelsForClusterNamespaceLabel(clusterNamespaceLabel)).To(Equal([]reconcile.Request{
+		{NamespacedName: types.NamespacedName{Name: namespaceLabel.Name, Namespace: namespaceLabel.Namespace}},
+	}))
+}
+
+func TestApplyNSLabels(t *testing.T) {
+	g := NewGomegaWithT(t)
+	RegisterFailHandler(ginkgo.Fail)
+
+	namespace := generateNamespaceObject()
+	fieldManager := "namespacelabel/default/namespacelabel-test"
+
+	labels := map[string]string{
+		"labelB": "testlabelB",
+		"labelC": "testlabelC2",
+	}
+	annotations := map[string]string{
+		"annotationB": "testannotationB",
+	}
+
+	obj := []client.Object{namespace}
+	cl, s, err := setupClient(obj)
+	if err != nil {
+		t.Fatalf("Unable to add to scheme: %v", err)
+	}
+
+	// create a NamespaceLabelReconciler object with the scheme and fake client
+	r := &NamespaceLabelReconciler{cl, s, record.NewFakeRecorder(10)}
+
+	// the annotation was applied by the same field manager before
+	seedAppliedKeys(cl, fieldManager, namespace.Name, nil, map[string]string{AnnotationKey: AnnotationVal})
+
+	// run function to test
+	if err := applyNSLabels(context.TODO(), r.Client, fieldManager, namespace, labels, annotations); err != nil {
+		t.Fatalf("Unable to apply NS Labels: %v", err)
+	}
+
+	if err := r.Get(context.TODO(), client.ObjectKeyFromObject(namespace), namespace); err != nil {
+		t.Fatalf("get: (%v)", err)
+	}
+
+	// set expected result and check result matches expected
+	g.Expect(func() bool {
+		expectedLabels := map[string]string{
+			"kubernetes.io/name": "default",
+			LabelKey:             LabelVal,
+			"labelB":             "testlabelB",
+			"labelC":             "testlabelC2",
+		}
+		expectedAnnotations := map[string]string{
+			"openshift.io/description": "default",
+			"annotationB":              "testannotationB",
+		}
+
+		return reflect.DeepEqual(namespace.ObjectMeta.Labels, expectedLabels) && reflect.DeepEqual(namespace.ObjectMeta.Annotations, expectedAnnotations)
+	}()).To(BeTrue())
+}
+
+func TestReconciler(t *testing.T) {
+	g := NewGomegaWithT(t)
+	RegisterFailHandler(ginkgo.Fail)
+
+	namespaceLabel := generateNamespacelabelObject()
+	namespace := generateNamespaceObject()
+
+	obj := []client.Object{namespaceLabel, namespace}
+
+	newStatusLabels := map[string]string{}
+	namespaceLabel.Status.ActiveLabels = newStatusLabels
+
+	cl, s, err := setupClient(obj)
+	if err != nil {
+		t.Fatalf("Unable to add to scheme: %v", err)
+	}
+
+	// create a NamespaceLabelReconciler object with the scheme and fake client
+	r := &NamespaceLabelReconciler{cl, s, record.NewFakeRecorder(10)}
+
+	// mock request to simulate Reconcile() being called on an event for a watched resource
+	req := reconcile.Request{
+		NamespacedName: types.NamespacedName{
+			Name:      namespaceLabel.ObjectMeta.Name,
+			Namespace: namespaceLabel.ObjectMeta.Namespace,
+		},
+	}
+
+	res, err := r.Reconcile(context.TODO(), req)
+	if err != nil {
+		t.Fatalf("Unable to reconcile: %v", err)
+	}
+
+	// check the result of reconciliation to make sure it has the desired state
+	if res.Requeue {
+		t.Error("reconcile did not requeue request as expected")
+	}
+
+	err = r.Get(context.TODO(), req.NamespacedName, namespaceLabel)
+	if err != nil {
+		t.Fatalf("get: (%v)", err)
+	}
+
+	// check if the status matches the expected status
+	g.Expect(func() bool {
+		expectedStatus := map[string]string{
+			LabelKey: LabelVal,
+		}
+		expectedAnnotationsStatus := map[string]string{
+			AnnotationKey: AnnotationVal,
+		}
+
+		return reflect.DeepEqual(namespaceLabel.Status.ActiveLabels, expectedStatus) && reflect.DeepEqual(namespaceLabel.Status.ActiveAnnotations, expectedAnnotationsStatus)
+	}()).To(BeTrue())
+
+	// check that the conditions report a successful sync
+	g.Expect(meta.IsStatusConditionTrue(namespaceLabel.Status.Conditions, danaiov1alpha1.ConditionReady)).To(BeTrue())
+	g.Expect(meta.IsStatusConditionTrue(namespaceLabel.Status.Conditions, danaiov1alpha1.ConditionSynced)).To(BeTrue())
+	g.Expect(namespaceLabel.Status.LastSyncTime).NotTo(BeNil())
+	g.Expect(namespaceLabel.Status.KeyResults).To(HaveLen(2))
+}
+
+func TestSetSyncFailedStatus(t *testing.T) {
+	g := NewGomegaWithT(t)
+	RegisterFailHandler(ginkgo.Fail)
+
+	namespaceLabel := generateNamespacelabelObject()
+
+	obj := []client.Object{namespaceLabel}
+	cl, s, err := setupClient(obj)
+	if err != nil {
+		t.Fatalf("Unable to add to scheme: %v", err)
+	}
+
+	// create a NamespaceLabelReconciler object with the scheme and fake client
+	r := &NamespaceLabelReconciler{cl, s, record.NewFakeRecorder(10)}
+
+	keyResults := r.getKeyResults(danaiov1alpha1.KeyTypeLabel, namespaceLabel.Spec.Labels, map[string]string{"kubernetes.io/name": "denied"}, map[string]string{})
+
+	// run function to test
+	r.setSyncFailedStatus(namespaceLabel, keyResults, fmt.Errorf("conflict"))
+
+	// set expected result and check result matches expected
+	g.Expect(meta.IsStatusConditionFalse(namespaceLabel.Status.Conditions, danaiov1alpha1.ConditionSynced)).To(BeTrue())
+	g.Expect(meta.IsStatusConditionTrue(namespaceLabel.Status.Conditions, danaiov1alpha1.ConditionDegraded)).To(BeTrue())
+	g.Expect(meta.IsStatusConditionFalse(namespaceLabel.Status.Conditions, danaiov1alpha1.ConditionReady)).To(BeTrue())
+	g.Expect(namespaceLabel.Status.KeyResults).To(Equal([]danaiov1alpha1.KeyResult{
+		{Key: LabelKey, Type: danaiov1alpha1.KeyTypeLabel, Result: danaiov1alpha1.KeyResultPending},
+		{Key: "kubernetes.io/name", Type: danaiov1alpha1.KeyTypeLabel, Result: danaiov1alpha1.KeyResultRejected, Message: "denied"},
+	}))
+}
+
+func TestReconcilerConflictPolicy(t *testing.T) {
+	g := NewGomegaWithT(t)
+	RegisterFailHandler(ginkgo.Fail)
+
+	tests := []struct {
+		policy         danaiov1alpha1.ConflictPolicy
+		expectedTeam   string
+		expectedSynced bool
+		expectedActive map[string]string
+	}{
+		{danaiov1alpha1.ConflictPolicySkip, "platform", true, map[string]string{LabelKey: LabelVal}},
+		{danaiov1alpha1.ConflictPolicyTakeover, "foo", true, map[string]string{LabelKey: LabelVal, "team": "foo"}},
+		{danaiov1alpha1.ConflictPolicyFail, "platform", false, nil},
+	}
+
+	for _, test := range tests {
+		namespaceLabel := generateNamespacelabelObject()
+		namespaceLabel.Spec.Labels["team"] = "foo"
+		namespaceLabel.Spec.ConflictPolicy = test.policy
+		namespaceLabel.Status = danaiov1alpha1.NamespaceLabelStatus{}
+
+		// the team label was set on the namespace by an admin
+		namespace := generateNamespaceObject()
+		namespace.Labels["team"] = "platform"
+
+		cl, s, err := setupClient([]client.Object{namespaceLabel, namespace})
+		if err != nil {
+			t.Fatalf("Unable to add to scheme: %v", err)
+		}
+
+		// create a NamespaceLabelReconciler object with the scheme and fake client
+		r := &NamespaceLabelReconciler{cl, s, record.NewFakeRecorder(10)}
+
+		req := reconcile.Request{
+			NamespacedName: types.NamespacedName{
+				Name:      namespaceLabel.ObjectMeta.Name,
+				Namespace: namespaceLabel.ObjectMeta.Namespace,
+			},
+		}
+
+		if _, err := r.Reconcile(context.TODO(), req); err != nil {
+			t.Fatalf("Unable to reconcile: %v", err)
+		}
+
+		if err := r.Get(context.TODO(), client.ObjectKeyFromObject(namespace), namespace); err != nil {
+			t.Fatalf("get: (%v)", err)
+		}
+		if err := r.Get(context.TODO(), req.NamespacedName, namespaceLabel); err != nil {
+			t.Fatalf("get: (%v)", err)
+		}
+
+		g.Expect(namespace.Labels["team"]).To(Equal(test.expectedTeam), "policy %s", test.policy)
+		g.Expect(meta.IsStatusConditionTrue(namespaceLabel.Status.Conditions, danaiov1alpha1.ConditionSynced)).To(Equal(test.expectedSynced), "policy %s", test.policy)
+		g.Expect(namespaceLabel.Status.ActiveLabels).To(Equal(test.expectedActive), "policy %s", test.policy)
+		if test.policy != danaiov1alpha1.ConflictPolicyTakeover {
+			continue
+		}
+
+		// the pre-existing value is remembered and restored once the key is released
+		g.Expect(namespaceLabel.Status.OriginalLabels).To(Equal(map[string]string{"team": "platform"}))
+
+		delete(namespaceLabel.Spec.Labels, "team")
+		if err := r.Update(context.TODO(), namespaceLabel); err != nil {
+			t.Fatalf("update: (%v)", err)
+		}
+		if _, err := r.Reconcile(context.TODO(), req); err != nil {
+			t.Fatalf("Unable to reconcile: %v", err)
+		}
+
+		if err := r.Get(context.TODO(), client.ObjectKeyFromObject(namespace), namespace); err != nil {
+			t.Fatalf("get: (%v)", err)
+		}
+		if err := r.Get(context.TODO(), req.NamespacedName, namespaceLabel); err != nil {
+			t.Fatalf("get: (%v)", err)
+		}
+		g.Expect(namespace.Labels["team"]).To(Equal("platform"))
+		g.Expect(namespaceLabel.Status.OriginalLabels).To(BeNil())
+	}
+}
+
+func TestGetReleasedOriginals(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	originalLabels := map[string]string{
+		"team":  "platform",
+		"owner": "admin",
+		"tier":  "gold",
+	}
+	reqLabels := map[string]string{
+		"team": "foo",
+	}
+	conflictLabels := map[string]string{
+		"tier": "key is owned by NamespaceLabel other",
+	}
+
+	// run function to test
+	restoreLabels, keptLabels := getReleasedOriginals(originalLabels, reqLabels, conflictLabels)
+
+	// set expected result and check result matches expected
+	g.Expect(restoreLabels).To(Equal(map[string]string{"owner": "admin"}))
+	g.Expect(keptLabels).To(Equal(map[string]string{"team": "platform"}))
+}
+
+func TestReconcilerQuotaExceeded(t *testing.T) {
+	g := NewGomegaWithT(t)
+	RegisterFailHandler(ginkgo.Fail)
+
+	namespaceLabel := generateNamespacelabelObject()
+	namespace := generateNamespaceObject()
+
+	// another NamespaceLabel in the namespace manages a second label
+	otherNamespaceLabel := generateNamespacelabelObject()
+	otherNamespaceLabel.Name = "namespacelabel-other"
+	otherNamespaceLabel.Spec.Labels = map[string]string{"other-key": "other-value"}
+	otherNamespaceLabel.Spec.Annotations = nil
+
+	// the limit was lowered after both NamespaceLabels were admitted
+	config := &configv1alpha1.NamespacelabelConfig{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: configv1alpha1.ClusterConfigName,
+		},
+		Spec: configv1alpha1.NamespacelabelConfigSpec{
+			Limits: configv1alpha1.NamespacelabelConfigLimits{
+				MaxLabelsPerNamespace: pointer.Int32(1),
+			},
+		},
+	}
+
+	cl, s, err := setupClient([]client.Object{namespaceLabel, otherNamespaceLabel, namespace, config})
+	if err != nil {
+		t.Fatalf("Unable to add to scheme: %v", err)
+	}
+
+	// create a NamespaceLabelReconciler object with the scheme and fake client
+	r := &NamespaceLabelReconciler{cl, s, record.NewFakeRecorder(10)}
+
+	req := reconcile.Request{
+		NamespacedName: types.NamespacedName{
+			Name:      namespaceLabel.ObjectMeta.Name,
+			Namespace: namespaceLabel.ObjectMeta.Namespace,
+		},
+	}
+
+	if _, err := r.Reconcile(context.TODO(), req); err != nil {
+		t.Fatalf("Unable to reconcile: %v", err)
+	}
+
+	if err := r.Get(context.TODO(), req.NamespacedName, namespaceLabel); err != nil {
+		t.Fatalf("get: (%v)", err)
+	}
+
+	// the quota is reported but the keys stay active
+	g.Expect(meta.IsStatusConditionTrue(namespaceLabel.Status.Conditions, danaiov1alpha1.ConditionQuotaExceeded)).To(BeTrue())
+	g.Expect(namespaceLabel.Status.ActiveLabels).To(Equal(map[string]string{LabelKey: LabelVal}))
+}
+
+func TestReconcilerQuotaCountsRenderedKeys(t *testing.T) {
+	g := NewGomegaWithT(t)
+	RegisterFailHandler(ginkgo.Fail)
+
+	// the template is longer than the value it renders to
+	namespaceLabel := generateNamespacelabelObject()
+	namespaceLabel.Spec.Labels = map[string]string{"ns": "{{ .Namespace.Name }}"}
+	namespaceLabel.Spec.Annotations = nil
+	namespaceLabel.Status = danaiov1alpha1.NamespaceLabelStatus{}
+	namespace := generateNamespaceObject()
+
+	config := &configv1alpha1.NamespacelabelConfig{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: configv1alpha1.ClusterConfigName,
+		},
+		Spec: configv1alpha1.NamespacelabelConfigSpec{
+			Limits: configv1alpha1.NamespacelabelConfigLimits{
+				MaxBytesPerNamespace: pointer.Int32(12),
+			},
+		},
+	}
+
+	cl, s, err := setupClient([]client.Object{namespaceLabel, namespace, config})
+	if err != nil {
+		t.Fatalf("Unable to add to scheme: %v", err)
+	}
+
+	// create a NamespaceLabelReconciler object with the scheme and fake client
+	r := &NamespaceLabelReconciler{cl, s, record.NewFakeRecorder(10)}
+
+	req := reconcile.Request{
+		NamespacedName: types.NamespacedName{
+			Name:      namespaceLabel.ObjectMeta.Name,
+			Namespace: namespaceLabel.ObjectMeta.Namespace,
+		},
+	}
+
+	if _, err := r.Reconcile(context.TODO(), req); err != nil {
+		t.Fatalf("Unable to reconcile: %v", err)
+	}
+
+	if err := r.Get(context.TODO(), req.NamespacedName, namespaceLabel); err != nil {
+		t.Fatalf("get: (%v)", err)
+	}
+
+	// the rendered label fits in the limit the webhook admitted it under
+	g.Expect(meta.IsStatusConditionTrue(namespaceLabel.Status.Conditions, danaiov1alpha1.ConditionQuotaExceeded)).To(BeFalse())
+	g.Expect(namespaceLabel.Status.ActiveLabels).To(Equal(map[string]string{"ns": namespace.Name}))
+}
+
+func TestReconcilerTemplates(t *testing.T) {
+	g := NewGomegaWithT(t)
+	RegisterFailHandler(ginkgo.Fail)
+
+	namespaceLabel := generateNamespacelabelObject()
+	namespaceLabel.Spec.Labels = map[string]string{
+		"description": `{{ index .Namespace.Annotations "openshift.io/description" }}`,
+		"invalid":     "{{ .Namespace.Name }} and more",
+	}
+	namespaceLabel.Status = danaiov1alpha1.NamespaceLabelStatus{}
+	namespace := generateNamespaceObject()
+
+	cl, s, err := setupClient([]client.Object{namespaceLabel, namespace})
+	if err != nil {
+		t.Fatalf("Unable to add to scheme: %v", err)
+	}
+
+	// create a NamespaceLabelReconciler object with the scheme and fake client
+	r := &NamespaceLabelReconciler{cl, s, record.NewFakeRecorder(10)}
+
+	req := reconcile.Request{NamespacedName: client.ObjectKeyFromObject(namespaceLabel)}
+	if _, err := r.Reconcile(context.TODO(), req); err != nil {
+		t.Fatalf("Unable to reconcile: %v", err)
+	}
+
+	// check that the rendered value is applied and the invalid one is reported as a template failure
+	if err := r.Get(context.TODO(), req.NamespacedName, namespaceLabel); err != nil {
+		t.Fatalf("get: (%v)", err)
+	}
+	g.Expect(namespaceLabel.Status.ActiveLabels).To(Equal(map[string]string{"description": "default"}))
+	g.Expect(namespaceLabel.Status.KeyResults).To(ContainElement(And(HaveField("Key", "invalid"), HaveField("Result", danaiov1alpha1.KeyResultTemplateFailed))))
+	degraded := meta.FindStatusCondition(namespaceLabel.Status.Conditions, danaiov1alpha1.ConditionDegraded)
+	g.Expect(degraded).NotTo(BeNil())
+	g.Expect(degraded.Reason).To(Equal(ReasonTemplateFailed))
+	g.Expect(degraded.Message).To(Equal("templates failed to render: invalid"))
+
+	if err := r.Get(context.TODO(), client.ObjectKeyFromObject(namespace), namespace); err != nil {
+		t.Fatalf("get: (%v)", err)
+	}
+	g.Expect(namespace.Labels).To(HaveKeyWithValue("description", "default"))
+	g.Expect(namespace.Labels).NotTo(HaveKey("invalid"))
+}
+
+func TestGetUnscheduledKeys(t *testing.T) {
+	g := NewGomegaWithT(t)
+	RegisterFailHandler(ginkgo.Fail)
+
+	now := time.Date(2022, 6, 1, 12, 0, 0, 0, time.UTC)
+	at := func(d time.Duration) *metav1.Time {
+		t := metav1.NewTime(now.Add(d))
+		return &t
+	}
+	schedules := []danaiov1alpha1.KeySchedule{
+		{Key: "maintenance", NotBefore: at(time.Hour), ExpiresAt: at(2 * time.Hour)},
+		{Key: "cost-exception", ExpiresAt: at(-time.Minute)},
+		{Key: "freeze", NotBefore: at(-time.Hour), ExpiresAt: at(time.Minute)},
+	}
+	reqKeys := map[string]string{"maintenance": "true", "cost-exception": "approved", "freeze": "true", "app": "web"}
+
+	// run function to test
+	unscheduledKeys := getUnscheduledKeys(schedules, reqKeys, now)
+
+	// set expected result and check result matches expected
+	g.Expect(reqKeys).To(Equal(map[string]string{"freeze": "true", "app": "web"}))
+	g.Expect(unscheduledKeys).To(HaveLen(2))
+	g.Expect(unscheduledKeys["maintenance"].Result).To(Equal(danaiov1alpha1.KeyResultScheduled))
+	g.Expect(unscheduledKeys["cost-exception"].Result).To(Equal(danaiov1alpha1.KeyResultExpired))
+
+	// the expired key has no transitions left, the others are ordered by time
+	transitions := getUpcomingTransitions(schedules, now)
+	g.Expect(transitions).To(Equal([]danaiov1alpha1.KeyTransition{
+		{Key: "freeze", Action: danaiov1alpha1.KeyTransitionRemove, Time: *at(time.Minute)},
+		{Key: "maintenance", Action: danaiov1alpha1.KeyTransitionAdd, Time: *at(time.Hour)},
+		{Key: "maintenance", Action: danaiov1alpha1.KeyTransitionRemove, Time: *at(2 * time.Hour)},
+	}))
+	g.Expect(nextTransitionAfter(transitions, now)).To(Equal(time.Minute))
+}
+
+func TestReconcilerSchedules(t *testing.T) {
+	g := NewGomegaWithT(t)
+	RegisterFailHandler(ginkgo.Fail)
+
+	notBefore := metav1.NewTime(time.Now().Add(time.Hour)).Rfc3339Copy()
+	namespaceLabel := generateNamespacelabelObject()
+	namespaceLabel.Spec.Labels["maintenance"] = "true"
+	namespaceLabel.Spec.Schedules = []danaiov1alpha1.KeySchedule{
+		{Key: "maintenance", NotBefore: &notBefore},
+	}
+	namespace := generateNamespaceObject()
+
+	cl, s, err := setupClient([]client.Object{namespaceLabel, namespace})
+	if err != nil {
+		t.Fatalf("Unable to add to scheme: %v", err)
+	}
+
+	// create a NamespaceLabelReconciler object with the scheme and fake client
+	r := &NamespaceLabelReconciler{cl, s, record.NewFakeRecorder(10)}
+
+	req := reconcile.Request{NamespacedName: client.ObjectKeyFromObject(namespaceLabel)}
+	res, err := r.Reconcile(context.TODO(), req)
+	if err != nil {
+		t.Fatalf("Unable to reconcile: %v", err)
+	}
+
+	// check that the reconcile is requeued for the scheduled key
+	g.Expect(res.RequeueAfter).To(BeNumerically("~", time.Hour, time.Minute))
+
+	// check that the scheduled key is not active yet and shows up as an upcoming transition
+	if err := r.Get(context.TODO(), req.NamespacedName, namespaceLabel); err != nil {
+		t.Fatalf("get: (%v)", err)
+	}
+	g.Expect(namespaceLabel.Status.ActiveLabels).NotTo(HaveKey("maintenance"))
+	g.Expect(namespaceLabel.Status.UpcomingTransitions).To(HaveLen(1))
+	g.Expect(namespaceLabel.Status.UpcomingTransitions[0].Action).To(Equal(danaiov1alpha1.KeyTransitionAdd))
+	g.Expect(namespaceLabel.Status.UpcomingTransitions[0].Time.Equal(&notBefore)).To(BeTrue())
+	g.Expect(meta.IsStatusConditionTrue(namespaceLabel.Status.Conditions, danaiov1alpha1.ConditionReady)).To(BeTrue())
+}
+
+func TestReconcilerSuspend(t *testing.T) {
+	g := NewGomegaWithT(t)
+	RegisterFailHandler(ginkgo.Fail)
+
+	namespaceLabel := generateNamespacelabelObject()
+	namespaceLabel.Spec.Suspend = true
+	namespaceLabel.Spec.Labels["team"] = "a"
+	namespace := generateNamespaceObject()
+
+	cl, s, err := setupClient([]client.Object{namespaceLabel, namespace})
+	if err != nil {
+		t.Fatalf("Unable to add to scheme: %v", err)
+	}
+
+	// create a NamespaceLabelReconciler object with the scheme and fake client
+	r := &NamespaceLabelReconciler{cl, s, record.NewFakeRecorder(10)}
+
+	req := reconcile.Request{NamespacedName: client.ObjectKeyFromObject(namespaceLabel)}
+	if _, err := r.Reconcile(context.TODO(), req); err != nil {
+		t.Fatalf("Unable to reconcile: %v", err)
+	}
+
+	// check that the namespace is left alone and the status reports the suspension
+	if err := r.Get(context.TODO(), client.ObjectKeyFromObject(namespace), namespace); err != nil {
+		t.Fatalf("get: (%v)", err)
+	}
+	g.Expect(namespace.Labels).NotTo(HaveKey("team"))
+	if err := r.Get(context.TODO(), req.NamespacedName, namespaceLabel); err != nil {
+		t.Fatalf("get: (%v)", err)
+	}
+	g.Expect(meta.IsStatusConditionTrue(namespaceLabel.Status.Conditions, danaiov1alpha1.ConditionSuspended)).To(BeTrue())
+	g.Expect(namespaceLabel.Status.ActiveLabels).NotTo(HaveKey("team"))
+
+	// check that resuming syncs the namespace and clears the condition
+	namespaceLabel.Spec.Suspend = false
+	if err := r.Update(context.TODO(), namespaceLabel); err != nil {
+		t.Fatalf("update: (%v)", err)
+	}
+	if _, err := r.Reconcile(context.TODO(), req); err != nil {
+		t.Fatalf("Unable to reconcile: %v", err)
+	}
+	if err := r.Get(context.TODO(), req.NamespacedName, namespaceLabel); err != nil {
+		t.Fatalf("get: (%v)", err)
+	}
+	g.Expect(meta.FindStatusCondition(namespaceLabel.Status.Conditions, danaiov1alpha1.ConditionSuspended)).To(BeNil())
+	g.Expect(namespaceLabel.Status.ActiveLabels).To(HaveKeyWithValue("team", "a"))
+}
+
+func TestReconcilerDryRun(t *testing.T) {
+	g := NewGomegaWithT(t)
+	RegisterFailHandler(ginkgo.Fail)
+
+	namespaceLabel := generateNamespacelabelObject()
+	namespaceLabel.Spec.DryRun = true
+	namespaceLabel.Spec.Labels["team"] = "a"
+	delete(namespaceLabel.Spec.Annotations, AnnotationKey)
+	namespace := generateNamespaceObject()
+
+	cl, s, err := setupClient([]client.Object{namespaceLabel, namespace})
+	if err != nil {
+		t.Fatalf("Unable to add to scheme: %v", err)
+	}
+
+	// create a NamespaceLabelReconciler object with the scheme and fake client
+	r := &NamespaceLabelReconciler{cl, s, record.NewFakeRecorder(10)}
+
+	req := reconcile.Request{NamespacedName: client.ObjectKeyFromObject(namespaceLabel)}
+	if _, err := r.Reconcile(context.TODO(), req); err != nil {
+		t.Fatalf("Unable to reconcile: %v", err)
+	}
+
+	// check that the namespace is untouched
+	if err := r.Get(context.TODO(), client.ObjectKeyFromObject(namespace), namespace); err != nil {
+		t.Fatalf("get: (%v)", err)
+	}
+	g.Expect(namespace.Labels).NotTo(HaveKey("team"))
+	g.Expect(namespace.Annotations).To(HaveKeyWithValue(AnnotationKey, AnnotationVal))
+
+	// check that the planned changes are recorded in the status and nothing is reported active
+	if err := r.Get(context.TODO(), req.NamespacedName, namespaceLabel); err != nil {
+		t.Fatalf("get: (%v)", err)
+	}
+	g.Expect(namespaceLabel.Status.PlannedChanges).To(Equal(&danaiov1alpha1.PlannedChanges{
+		AddLabels:         map[string]string{"team": "a"},
+		DeleteAnnotations: map[string]string{AnnotationKey: AnnotationVal},
+	}))
+	g.Expect(namespaceLabel.Status.ActiveLabels).NotTo(HaveKey("team"))
+	g.Expect(namespaceLabel.Status.ActiveAnnotations).To(HaveKey(AnnotationKey))
+	synced := meta.FindStatusCondition(namespaceLabel.Status.Conditions, danaiov1alpha1.ConditionSynced)
+	g.Expect(synced).NotTo(BeNil())
+	g.Expect(synced.Status).To(Equal(metav1.ConditionFalse))
+	g.Expect(synced.Reason).To(Equal(ReasonDryRun))
+}
+
+func TestReconcilerRevisions(t *testing.T) {
+	g := NewGomegaWithT(t)
+	RegisterFailHandler(ginkgo.Fail)
+
+	namespaceLabel := generateNamespacelabelObject()
+	namespaceLabel.UID = "namespacelabel-test-uid"
+	namespaceLabel.Annotations = map[string]string{danaiov1alpha1.ChangedByAnnotation: "alice"}
+	namespace := generateNamespaceObject()
+
+	// only the two latest revisions are kept
+	historyLimit := int32(2)
+	config := &configv1alpha1.NamespacelabelConfig{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: configv1alpha1.ClusterConfigName,
+		},
+		Spec: configv1alpha1.NamespacelabelConfigSpec{
+			RevisionHistoryLimit: &historyLimit,
+		},
+	}
+
+	// a revision left behind by a deleted NamespaceLabel of the same name
+	isController := true
+	stale := &danaiov1alpha1.NamespaceLabelRevision{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      namespaceLabel.Name + "-1",
+			Namespace: namespaceLabel.Namespace,
+			Labels:    map[string]string{danaiov1alpha1.RevisionOfLabel: "deleted-uid"},
+			OwnerReferences: []metav1.OwnerReference{{
+				APIVersion: danaiov1alpha1.GroupVersion.String(),
+				Kind:       "NamespaceLabel",
+				Name:       namespaceLabel.Name,
+				UID:        "deleted-uid",
+				Controller: &isController,
+			}},
+		},
+		Spec: danaiov1alpha1.NamespaceLabelRevisionSpec{Revision: 1, Labels: map[string]string{"stale": "true"}},
+	}
+
+	cl, s, err := setupClient([]client.Object{namespaceLabel, namespace, config, stale})
+	if err != nil {
+		t.Fatalf("Unable to add to scheme: %v", err)
+	}
+
+	// create a NamespaceLabelReconciler object with the scheme and fake client
+	r := &NamespaceLabelReconciler{cl, s, record.NewFakeRecorder(10)}
+
+	req := reconcile.Request{NamespacedName: client.ObjectKeyFromObject(namespaceLabel)}
+	reconcileWith := func(change func(*danaiov1alpha1.NamespaceLabel)) {
+		if err := r.Get(context.TODO(), req.NamespacedName, namespaceLabel); err != nil {
+			t.Fatalf("get: (%v)", err)
+		}
+		change(namespaceLabel)
+		if err := r.Update(context.TODO(), namespaceLabel); err != nil {
+			t.Fatalf("update: (%v)", err)
+		}
+		if _, err := r.Reconcile(context.TODO(), req); err != nil {
+			t.Fatalf("Unable to reconcile: %v", err)
+		}
+	}
+	revisionLabels := func() map[int64]map[string]string {
+		revisions := &danaiov1alpha1.NamespaceLabelRevisionList{}
+		if err := r.List(context.TODO(), revisions, client.InNamespace(namespaceLabel.Namespace)); err != nil {
+			t.Fatalf("list: (%v)", err)
+		}
+		labels := make(map[int64]map[string]string)
+		for _, revision := range revisions.Items {
+			if !metav1.IsControlledBy(&revision, namespaceLabel) {
+				continue
+			}
+			labels[revision.Spec.Revision] = revision.Spec.Labels
+		}
+		return labels
+	}
+
+	// check that the first sync records a revision credited to the user who made the change
+	reconcileWith(func(*danaiov1alpha1.NamespaceLabel) {})
+	revision, err := danaiov1alpha1.GetRevision(context.TODO(), r, namespaceLabel, 1)
+	if err != nil {
+		t.Fatalf("get: (%v)", err)
+	}
+	g.Expect(revision).NotTo(BeNil())
+	g.Expect(revision.Spec.Author).To(Equal("alice"))
+	g.Expect(revision.Spec.Labels).To(Equal(map[string]string{LabelKey: LabelVal}))
+
+	// check that a sync without changes records nothing
+	reconcileWith(func(*danaiov1alpha1.NamespaceLabel) {})
+	g.Expect(revisionLabels()).To(HaveLen(1))
+
+	// check that every change is recorded and the history is capped
+	reconcileWith(func(nl *danaiov1alpha1.NamespaceLabel) { nl.Spec.Labels = map[string]string{"team": "a"} })
+	reconcileWith(func(nl *danaiov1alpha1.NamespaceLabel) { nl.Spec.Labels = map[string]string{"team": "b"} })
+	g.Expect(revisionLabels()).To(Equal(map[int64]map[string]string{
+		2: {"team": "a"},
+		3: {"team": "b"},
+	}))
+	if err := r.Get(context.TODO(), req.NamespacedName, namespaceLabel); err != nil {
+		t.Fatalf("get: (%v)", err)
+	}
+	g.Expect(namespaceLabel.Status.CurrentRevision).To(Equal(int64(3)))
+
+	// check that the revision of the deleted NamespaceLabel is left to the garbage collector
+	if err := r.Get(context.TODO(), client.ObjectKeyFromObject(stale), stale); err != nil {
+		t.Fatalf("get: (%v)", err)
+	}
+
+	// check that a number recorded again from a stale cache is refused rather than duplicated
+	_, err = r.createRevision(context.TODO(), namespaceLabel, &danaiov1alpha1.NamespaceLabelRevision{Spec: danaiov1alpha1.NamespaceLabelRevisionSpec{Revision: 2}})
+	g.Expect(errors.IsAlreadyExists(err)).To(BeTrue())
+}
+
+func TestGetRevisionAuthor(t *testing.T) {
+	g := NewGomegaWithT(t)
+	RegisterFailHandler(ginkgo.Fail)
+
+	earlier := metav1.NewTime(time.Date(2022, 6, 1, 12, 0, 0, 0, time.UTC))
+	later := metav1.NewTime(earlier.Add(time.Hour))
+	namespaceLabel := generateNamespacelabelObject()
+	namespaceLabel.ManagedFields = []metav1.ManagedFieldsEntry{
+		{Manager: "kubectl-client-side-apply", Time: &earlier, FieldsV1: &metav1.FieldsV1{Raw: []byte(`{"f:spec":{"f:labels":{}}}`)}},
+		{Manager: "kubectl-edit", Time: &later, FieldsV1: &metav1.FieldsV1{Raw: []byte(`{"f:spec":{"f:labels":{}}}`)}},
+		{Manager: "manager", Time: &later, Subresource: "status", FieldsV1: &metav1.FieldsV1{Raw: []byte(`{"f:status":{}}`)}},
+	}
+
+	// check that the managed fields are used without the annotation of the webhook
+	g.Expect(getRevisionAuthor(namespaceLabel)).To(Equal("kubectl-edit"))
+
+	namespaceLabel.Annotations = map[string]string{danaiov1alpha1.ChangedByAnnotation: "alice"}
+	g.Expect(getRevisionAuthor(namespaceLabel)).To(Equal("alice"))
+}
+
+func TestRenderKeysNormalize(t *testing.T) {
+	g := NewGomegaWithT(t)
+	RegisterFailHandler(ginkgo.Fail)
+
+	namespace := generateNamespaceObject()
+	namespace.Annotations["owner"] = " Alice "
+	defaults := &configv1alpha1.NamespacelabelConfigDefaults{TrimValues: true, LowercaseValues: true}
+
+	// only rendered templates are normalized, static values were normalized at admission
+	rendered, failed := renderKeys(map[string]string{
+		"owner":  "{{ .Namespace.Annotations.owner }}",
+		"static": "Web",
+	}, namespace, true, defaults.NormalizeValue)
+	g.Expect(failed).To(BeEmpty())
+	g.Expect(rendered).To(Equal(map[string]string{"owner": "alice", "static": "Web"}))
+}
@@ -0,0 +1,167 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	danaiov1alpha1 "home-assignment/apis/namespacelabel/v1alpha1"
+)
+
+// recordRevision records the labels and annotations of the NamespaceLabel as a new revision when
+// they differ from the latest one, and deletes the revisions beyond the history limit. The number
+// of the latest revision is set in the status
+func (r *NamespaceLabelReconciler) recordRevision(ctx context.Context, namespaceLabel *danaiov1alpha1.NamespaceLabel, historyLimit int) error {
+	log := log.FromContext(ctx)
+
+	revisions, err := r.getRevisions(ctx, namespaceLabel)
+	if err != nil {
+		log.Error(err, "unable to list namespaceLabelRevisions")
+		return err
+	}
+
+	// without a history limit every revision is dropped and none is recorded
+	if historyLimit > 0 {
+		var latest *danaiov1alpha1.NamespaceLabelRevision
+		if len(revisions) > 0 {
+			latest = &revisions[len(revisions)-1]
+		}
+		if latest == nil || !equality.Semantic.DeepEqual(latest.Spec.Labels, namespaceLabel.Spec.Labels) ||
+			!equality.Semantic.DeepEqual(latest.Spec.Annotations, namespaceLabel.Spec.Annotations) {
+			revision, err := r.createRevision(ctx, namespaceLabel, latest)
+			if err != nil {
+				log.Error(err, "unable to create namespaceLabelRevision")
+				return err
+			}
+			revisions = append(revisions, *revision)
+		}
+	}
+
+	namespaceLabel.Status.CurrentRevision = 0
+	if len(revisions) > 0 {
+		namespaceLabel.Status.CurrentRevision = revisions[len(revisions)-1].Spec.Revision
+	}
+
+	for i := 0; i < len(revisions)-historyLimit; i++ {
+		if err := r.Delete(ctx, &revisions[i]); client.IgnoreNotFound(err) != nil {
+			log.Error(err, "unable to delete namespaceLabelRevision", "revision", revisions[i].Spec.Revision)
+			return err
+		}
+	}
+
+	return nil
+}
+
+// getRevisions returns the revisions of the NamespaceLabel ordered by their number, revisions
+// left behind by a deleted NamespaceLabel of the same name are not included
+func (r *NamespaceLabelReconciler) getRevisions(ctx context.Context, namespaceLabel *danaiov1alpha1.NamespaceLabel) ([]danaiov1alpha1.NamespaceLabelRevision, error) {
+	revisionList := &danaiov1alpha1.NamespaceLabelRevisionList{}
+	if err := r.List(ctx, revisionList, client.InNamespace(namespaceLabel.Namespace), client.MatchingLabels{danaiov1alpha1.RevisionOfLabel: string(namespaceLabel.UID)}); err != nil {
+		return nil, err
+	}
+
+	revisions := []danaiov1alpha1.NamespaceLabelRevision{}
+	for _, revision := range revisionList.Items {
+		if metav1.IsControlledBy(&revision, namespaceLabel) {
+			revisions = append(revisions, revision)
+		}
+	}
+	sort.Slice(revisions, func(i, j int) bool {
+		return revisions[i].Spec.Revision < revisions[j].Spec.Revision
+	})
+
+	return revisions, nil
+}
+
+// createRevision creates the revision following the latest one, owned by the NamespaceLabel
+// so it is garbage collected along with it. The name is made of the UID of the NamespaceLabel and
+// the number, so revisions of a deleted NamespaceLabel of the same name never collide with it and
+// a number recorded twice from a stale cache fails with AlreadyExists and is retried
+func (r *NamespaceLabelReconciler) createRevision(ctx context.Context, namespaceLabel *danaiov1alpha1.NamespaceLabel, latest *danaiov1alpha1.NamespaceLabelRevision) (*danaiov1alpha1.NamespaceLabelRevision, error) {
+	number := int64(1)
+	if latest != nil {
+		number = latest.Spec.Revision + 1
+	}
+
+	revision := &danaiov1alpha1.NamespaceLabelRevision{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("%s-%d", namespaceLabel.UID, number),
+			Namespace: namespaceLabel.Namespace,
+			Labels:    map[string]string{danaiov1alpha1.RevisionOfLabel: string(namespaceLabel.UID)},
+		},
+		Spec: danaiov1alpha1.NamespaceLabelRevisionSpec{
+			Revision:    number,
+			Labels:      copyKeys(namespaceLabel.Spec.Labels),
+			Annotations: copyKeys(namespaceLabel.Spec.Annotations),
+			Author:      getRevisionAuthor(namespaceLabel),
+		},
+	}
+	if err := controllerutil.SetControllerReference(namespaceLabel, revision, r.Scheme); err != nil {
+		return nil, err
+	}
+	if err := r.Create(ctx, revision); err != nil {
+		return nil, err
+	}
+
+	return revision, nil
+}
+
+// getRevisionAuthor returns the user who last changed the spec of the NamespaceLabel, as recorded
+// by the webhook. Without the annotation it falls back to the manager that last updated the spec
+// according to the managed fields
+func getRevisionAuthor(namespaceLabel *danaiov1alpha1.NamespaceLabel) string {
+	if author := namespaceLabel.Annotations[danaiov1alpha1.ChangedByAnnotation]; author != "" {
+		return author
+	}
+
+	author := ""
+	var latest *metav1.Time
+	for _, entry := range namespaceLabel.ManagedFields {
+		if entry.Subresource != "" || entry.FieldsV1 == nil || !strings.Contains(string(entry.FieldsV1.Raw), `"f:spec"`) {
+			continue
+		}
+		if latest == nil || (entry.Time != nil && latest.Before(entry.Time)) {
+			author = entry.Manager
+			latest = entry.Time
+		}
+	}
+
+	return author
+}
+
+// copyKeys returns a copy of the labels or annotations, or nil when there are none
+func copyKeys(keys map[string]string) map[string]string {
+	if len(keys) == 0 {
+		return nil
+	}
+
+	copied := make(map[string]string, len(keys))
+	for key, val := range keys {
+		copied[key] = val
+	}
+
+	return copied
+}
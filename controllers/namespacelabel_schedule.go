@@ -0,0 +1,130 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	danaiov1alpha1 "home-assignment/apis/namespacelabel/v1alpha1"
+)
+
+// this function moves the requested keys that are outside of their schedule at the given time
+// from reqKeys to the returned map, which holds the result of each key: Scheduled for keys
+// whose window has not started yet and Expired for keys whose window has ended
+func getUnscheduledKeys(schedules []danaiov1alpha1.KeySchedule, reqKeys map[string]string, now time.Time) map[string]danaiov1alpha1.KeyResult {
+	unscheduledKeys := make(map[string]danaiov1alpha1.KeyResult)
+
+	for _, schedule := range schedules {
+		if _, ok := reqKeys[schedule.Key]; !ok {
+			continue
+		}
+
+		if schedule.ExpiresAt != nil && !now.Before(schedule.ExpiresAt.Time) {
+			unscheduledKeys[schedule.Key] = danaiov1alpha1.KeyResult{
+				Key:     schedule.Key,
+				Result:  danaiov1alpha1.KeyResultExpired,
+				Message: fmt.Sprintf("key expired at %s", schedule.ExpiresAt.UTC().Format(time.RFC3339)),
+			}
+		} else if schedule.NotBefore != nil && now.Before(schedule.NotBefore.Time) {
+			unscheduledKeys[schedule.Key] = danaiov1alpha1.KeyResult{
+				Key:     schedule.Key,
+				Result:  danaiov1alpha1.KeyResultScheduled,
+				Message: fmt.Sprintf("key is added at %s", schedule.NotBefore.UTC().Format(time.RFC3339)),
+			}
+		} else {
+			continue
+		}
+		delete(reqKeys, schedule.Key)
+	}
+
+	return unscheduledKeys
+}
+
+// this function returns the additions and removals of scheduled keys that happen after the
+// given time, ordered by time. A key whose window has already ended has no transitions left
+func getUpcomingTransitions(schedules []danaiov1alpha1.KeySchedule, now time.Time) []danaiov1alpha1.KeyTransition {
+	transitions := []danaiov1alpha1.KeyTransition{}
+
+	for _, schedule := range schedules {
+		if schedule.ExpiresAt != nil && !now.Before(schedule.ExpiresAt.Time) {
+			continue
+		}
+		if schedule.NotBefore != nil && now.Before(schedule.NotBefore.Time) {
+			transitions = append(transitions, danaiov1alpha1.KeyTransition{
+				Key:    schedule.Key,
+				Action: danaiov1alpha1.KeyTransitionAdd,
+				Time:   *schedule.NotBefore,
+			})
+		}
+		if schedule.ExpiresAt != nil {
+			transitions = append(transitions, danaiov1alpha1.KeyTransition{
+				Key:    schedule.Key,
+				Action: danaiov1alpha1.KeyTransitionRemove,
+				Time:   *schedule.ExpiresAt,
+			})
+		}
+	}
+
+	sort.SliceStable(transitions, func(i, j int) bool {
+		if !transitions[i].Time.Equal(&transitions[j].Time) {
+			return transitions[i].Time.Before(&transitions[j].Time)
+		}
+		return transitions[i].Key < transitions[j].Key
+	})
+
+	return transitions
+}
+
+// nextTransitionAfter returns the time until the first of the upcoming transitions,
+// or zero when there is none so no requeue is scheduled
+func nextTransitionAfter(transitions []danaiov1alpha1.KeyTransition, now time.Time) time.Duration {
+	if len(transitions) == 0 {
+		return 0
+	}
+
+	return transitions[0].Time.Sub(now)
+}
+
+// nilIfNoTransitions returns nil for an empty list so it is omitted from the status
+func nilIfNoTransitions(transitions []danaiov1alpha1.KeyTransition) []danaiov1alpha1.KeyTransition {
+	if len(transitions) == 0 {
+		return nil
+	}
+
+	return transitions
+}
+
+// scheduleResults returns the results of the unscheduled keys of the given type, sorted by key
+func scheduleResults(keyType danaiov1alpha1.KeyType, unscheduledKeys map[string]danaiov1alpha1.KeyResult) []danaiov1alpha1.KeyResult {
+	keyResults := []danaiov1alpha1.KeyResult{}
+
+	keys := make([]string, 0, len(unscheduledKeys))
+	for key := range unscheduledKeys {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		keyResult := unscheduledKeys[key]
+		keyResult.Type = keyType
+		keyResults = append(keyResults, keyResult)
+	}
+
+	return keyResults
+}
@@ -0,0 +1,297 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	danaiov1alpha1 "home-assignment/apis/namespacelabel/v1alpha1"
+)
+
+// Reasons used in the conditions of a NamespaceLabel
+const (
+	ReasonSynced          = "Synced"
+	ReasonSyncFailed      = "SyncFailed"
+	ReasonNoConflict      = "NoConflict"
+	ReasonKeysRejected    = "KeysRejected"
+	ReasonTemplateFailed  = "TemplateFailed"
+	ReasonAllKeysAccepted = "AllKeysAccepted"
+	ReasonReady           = "Ready"
+	ReasonWithinQuota     = "WithinQuota"
+	ReasonQuotaExceeded   = "QuotaExceeded"
+	ReasonSuspended       = "Suspended"
+	ReasonDryRun          = "DryRun"
+)
+
+// Reasons used in the events of a NamespaceLabel
+const (
+	ReasonNamespaceNotFound    = "NamespaceNotFound"
+	ReasonNamespaceTerminating = "NamespaceTerminating"
+)
+
+// this function builds the per-key results of a NamespaceLabel from the requested keys
+// that were accepted, the keys that were rejected and the keys owned by another source,
+// along with the reason they were not applied
+func (r *NamespaceLabelReconciler) getKeyResults(keyType danaiov1alpha1.KeyType, reqKeys map[string]string, rejectedKeys map[string]string, conflictKeys map[string]string) []danaiov1alpha1.KeyResult {
+	keyResults := []danaiov1alpha1.KeyResult{}
+
+	for _, key := range sortedKeys(reqKeys) {
+		keyResults = append(keyResults, danaiov1alpha1.KeyResult{
+			Key:    key,
+			Type:   keyType,
+			Result: danaiov1alpha1.KeyResultApplied,
+		})
+	}
+
+	for _, key := range sortedKeys(rejectedKeys) {
+		keyResults = append(keyResults, danaiov1alpha1.KeyResult{
+			Key:     key,
+			Type:    keyType,
+			Result:  danaiov1alpha1.KeyResultRejected,
+			Message: rejectedKeys[key],
+		})
+	}
+
+	for _, key := range sortedKeys(conflictKeys) {
+		keyResults = append(keyResults, danaiov1alpha1.KeyResult{
+			Key:     key,
+			Type:    keyType,
+			Result:  danaiov1alpha1.KeyResultConflict,
+			Message: conflictKeys[key],
+		})
+	}
+
+	return keyResults
+}
+
+// this function builds the per-key results of the keys whose template failed to render
+func (r *NamespaceLabelReconciler) getTemplateResults(keyType danaiov1alpha1.KeyType, failedKeys map[string]string) []danaiov1alpha1.KeyResult {
+	keyResults := []danaiov1alpha1.KeyResult{}
+
+	for _, key := range sortedKeys(failedKeys) {
+		keyResults = append(keyResults, danaiov1alpha1.KeyResult{
+			Key:     key,
+			Type:    keyType,
+			Result:  danaiov1alpha1.KeyResultTemplateFailed,
+			Message: failedKeys[key],
+		})
+	}
+
+	return keyResults
+}
+
+// setSyncedStatus records a successful sync of the namespace in the status of the NamespaceLabel
+func (r *NamespaceLabelReconciler) setSyncedStatus(namespaceLabel *danaiov1alpha1.NamespaceLabel, keyResults []danaiov1alpha1.KeyResult, changed bool) {
+	status := &namespaceLabel.Status
+
+	if changed || status.LastSyncTime == nil || status.ObservedGeneration != namespaceLabel.Generation {
+		now := metav1.Now()
+		status.LastSyncTime = &now
+	}
+	status.ObservedGeneration = namespaceLabel.Generation
+	status.KeyResults = keyResults
+
+	meta.SetStatusCondition(&status.Conditions, metav1.Condition{
+		Type:               danaiov1alpha1.ConditionSynced,
+		Status:             metav1.ConditionTrue,
+		Reason:             ReasonSynced,
+		Message:            "namespace is in sync with the requested keys",
+		ObservedGeneration: namespaceLabel.Generation,
+	})
+
+	r.setKeyConditions(namespaceLabel)
+}
+
+// setSyncFailedStatus records a failed sync of the namespace in the status of the NamespaceLabel,
+// keys that were not yet applied are marked as pending
+func (r *NamespaceLabelReconciler) setSyncFailedStatus(namespaceLabel *danaiov1alpha1.NamespaceLabel, keyResults []danaiov1alpha1.KeyResult, err error) {
+	status := &namespaceLabel.Status
+
+	status.ObservedGeneration = namespaceLabel.Generation
+	for i := range keyResults {
+		if keyResults[i].Result == danaiov1alpha1.KeyResultApplied {
+			keyResults[i].Result = danaiov1alpha1.KeyResultPending
+		}
+	}
+	status.KeyResults = keyResults
+
+	meta.SetStatusCondition(&status.Conditions, metav1.Condition{
+		Type:               danaiov1alpha1.ConditionSynced,
+		Status:             metav1.ConditionFalse,
+		Reason:             ReasonSyncFailed,
+		Message:            err.Error(),
+		ObservedGeneration: namespaceLabel.Generation,
+	})
+
+	r.setKeyConditions(namespaceLabel)
+}
+
+// setSuspendedStatus records in the status of the NamespaceLabel that syncing is suspended
+func (r *NamespaceLabelReconciler) setSuspendedStatus(namespaceLabel *danaiov1alpha1.NamespaceLabel) {
+	status := &namespaceLabel.Status
+
+	status.ObservedGeneration = namespaceLabel.Generation
+	meta.SetStatusCondition(&status.Conditions, metav1.Condition{
+		Type:               danaiov1alpha1.ConditionSuspended,
+		Status:             metav1.ConditionTrue,
+		Reason:             ReasonSuspended,
+		Message:            "syncing is suspended, the namespace is left as it is",
+		ObservedGeneration: namespaceLabel.Generation,
+	})
+}
+
+// setDryRunStatus records a dry run sync in the status of the NamespaceLabel, the namespace is
+// only reported in sync when no changes are planned
+func (r *NamespaceLabelReconciler) setDryRunStatus(namespaceLabel *danaiov1alpha1.NamespaceLabel, keyResults []danaiov1alpha1.KeyResult, planned bool) {
+	status := &namespaceLabel.Status
+
+	status.ObservedGeneration = namespaceLabel.Generation
+	status.KeyResults = keyResults
+
+	synced := metav1.Condition{
+		Type:               danaiov1alpha1.ConditionSynced,
+		Status:             metav1.ConditionTrue,
+		Reason:             ReasonDryRun,
+		Message:            "dry run, namespace is in sync with the requested keys",
+		ObservedGeneration: namespaceLabel.Generation,
+	}
+	if planned {
+		synced.Status = metav1.ConditionFalse
+		synced.Message = "dry run, changes to the namespace are planned in the status"
+	}
+	meta.SetStatusCondition(&status.Conditions, synced)
+
+	r.setKeyConditions(namespaceLabel)
+}
+
+// setKeyConditions derives the Degraded, Conflict and Ready conditions from the
+// per-key results and the Synced condition of the NamespaceLabel
+func (r *NamespaceLabelReconciler) setKeyConditions(namespaceLabel *danaiov1alpha1.NamespaceLabel) {
+	status := &namespaceLabel.Status
+
+	rejected := []string{}
+	failed := []string{}
+	conflicting := []string{}
+	for _, keyResult := range status.KeyResults {
+		switch keyResult.Result {
+		case danaiov1alpha1.KeyResultRejected:
+			rejected = append(rejected, keyResult.Key)
+		case danaiov1alpha1.KeyResultTemplateFailed:
+			failed = append(failed, keyResult.Key)
+		case danaiov1alpha1.KeyResultConflict:
+			conflicting = append(conflicting, keyResult.Key)
+		}
+	}
+
+	degraded := metav1.Condition{
+		Type:               danaiov1alpha1.ConditionDegraded,
+		Status:             metav1.ConditionFalse,
+		Reason:             ReasonAllKeysAccepted,
+		Message:            "all requested keys were accepted",
+		ObservedGeneration: namespaceLabel.Generation,
+	}
+	// template failures are reported apart, they are fixed in the NamespaceLabel and not in the policy
+	messages := []string{}
+	if len(failed) > 0 {
+		degraded.Reason = ReasonTemplateFailed
+		messages = append(messages, fmt.Sprintf("templates failed to render: %s", strings.Join(failed, ", ")))
+	}
+	if len(rejected) > 0 {
+		degraded.Reason = ReasonKeysRejected
+		messages = append(messages, fmt.Sprintf("keys rejected by cluster policy: %s", strings.Join(rejected, ", ")))
+	}
+	if len(messages) > 0 {
+		degraded.Status = metav1.ConditionTrue
+		degraded.Message = strings.Join(messages, "; ")
+	}
+	meta.SetStatusCondition(&status.Conditions, degraded)
+
+	conflict := metav1.Condition{
+		Type:               danaiov1alpha1.ConditionConflict,
+		Status:             metav1.ConditionFalse,
+		Reason:             ReasonNoConflict,
+		Message:            "no requested key is owned by another source",
+		ObservedGeneration: namespaceLabel.Generation,
+	}
+	if len(conflicting) > 0 {
+		conflict.Status = metav1.ConditionTrue
+		conflict.Reason = string(danaiov1alpha1.KeyResultConflict)
+		conflict.Message = fmt.Sprintf("keys owned by another source: %s", strings.Join(conflicting, ", "))
+	}
+	meta.SetStatusCondition(&status.Conditions, conflict)
+
+	ready := metav1.Condition{
+		Type:               danaiov1alpha1.ConditionReady,
+		Status:             metav1.ConditionTrue,
+		Reason:             ReasonReady,
+		Message:            "all requested keys are active on the namespace",
+		ObservedGeneration: namespaceLabel.Generation,
+	}
+	for _, condType := range []string{danaiov1alpha1.ConditionSynced, danaiov1alpha1.ConditionDegraded, danaiov1alpha1.ConditionConflict} {
+		cond := meta.FindStatusCondition(status.Conditions, condType)
+		if cond == nil {
+			continue
+		}
+
+		// Synced must be true, Degraded and Conflict must be false
+		if (condType == danaiov1alpha1.ConditionSynced) != (cond.Status == metav1.ConditionTrue) {
+			ready.Status = metav1.ConditionFalse
+			ready.Reason = cond.Reason
+			ready.Message = cond.Message
+			break
+		}
+	}
+	meta.SetStatusCondition(&status.Conditions, ready)
+}
+
+// setQuotaCondition records whether the keys of the NamespaceLabel exceed the limits of the cluster policy.
+// The keys stay active, the limits are enforced when the NamespaceLabel is admitted
+func (r *NamespaceLabelReconciler) setQuotaCondition(namespaceLabel *danaiov1alpha1.NamespaceLabel, quotaErrs []error) {
+	quota := metav1.Condition{
+		Type:               danaiov1alpha1.ConditionQuotaExceeded,
+		Status:             metav1.ConditionFalse,
+		Reason:             ReasonWithinQuota,
+		Message:            "keys are within the limits of the cluster policy",
+		ObservedGeneration: namespaceLabel.Generation,
+	}
+	if len(quotaErrs) > 0 {
+		msgs := make([]string, 0, len(quotaErrs))
+		for _, err := range quotaErrs {
+			msgs = append(msgs, err.Error())
+		}
+		quota.Status = metav1.ConditionTrue
+		quota.Reason = ReasonQuotaExceeded
+		quota.Message = strings.Join(msgs, "; ")
+	}
+	meta.SetStatusCondition(&namespaceLabel.Status.Conditions, quota)
+}
+
+// sortedKeys returns the keys of the map in a deterministic order
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
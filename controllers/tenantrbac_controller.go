@@ -0,0 +1,233 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+
+	v1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	configv1alpha1 "home-assignment/apis/config/v1alpha1"
+	danaiov1alpha1 "home-assignment/apis/namespacelabel/v1alpha1"
+)
+
+const (
+	// TenantClusterRoleName is the name of the generated ClusterRole that grants the use of NamespaceLabel
+	TenantClusterRoleName = "namespacelabel-tenant"
+	// TenantRoleBindingName is the name of the RoleBinding created in every tenant namespace
+	TenantRoleBindingName = "namespacelabel-tenant"
+
+	managedByLabel = "app.kubernetes.io/managed-by"
+	managedByValue = "namespacelabel-operator"
+)
+
+// TenantRBACReconciler binds the tenant ClusterRole in every namespace selected by the
+// tenant RBAC of the cluster NamespacelabelConfig
+type TenantRBACReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+}
+
+//+kubebuilder:rbac:groups=*,resources=namespaces,verbs=get;list;watch
+//+kubebuilder:rbac:groups=config.dana.io,resources=namespacelabelconfigs,verbs=get;list;watch
+// Writes are limited to the tenant ClusterRole and RoleBindings by name, except for create which RBAC
+// can not limit by name. The manager holds every permission of the tenant ClusterRole, so it needs
+// neither escalate nor bind to create or bind it
+//+kubebuilder:rbac:groups=rbac.authorization.k8s.io,resources=clusterroles,verbs=get;list;watch;create
+//+kubebuilder:rbac:groups=rbac.authorization.k8s.io,resources=clusterroles,verbs=update;patch,resourceNames=namespacelabel-tenant
+//+kubebuilder:rbac:groups=rbac.authorization.k8s.io,resources=rolebindings,verbs=get;list;watch;create
+//+kubebuilder:rbac:groups=rbac.authorization.k8s.io,resources=rolebindings,verbs=update;patch;delete,resourceNames=namespacelabel-tenant
+
+// Reconcile creates the tenant RoleBinding in a namespace selected as a tenant namespace
+// and removes it from a namespace that is no longer selected
+func (r *TenantRBACReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+	log.Info("Processing TenantRBACReconciler")
+
+	namespace := &v1.Namespace{}
+	if err := r.Get(ctx, types.NamespacedName{Name: req.Name}, namespace); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	config, err := configv1alpha1.GetClusterConfig(ctx, r.Client)
+	if err != nil {
+		log.Error(err, "unable to get NamespacelabelConfig")
+		return ctrl.Result{}, err
+	}
+	tenantRBAC := config.Spec.TenantRBAC
+
+	isTenant, err := tenantRBAC.IsTenantNamespace(namespace.Labels)
+	if err != nil {
+		log.Error(err, "invalid tenant namespace selector")
+		return ctrl.Result{}, nil
+	}
+
+	// a terminating namespace does not accept new objects, its RoleBinding goes away with it
+	if !isTenant || namespace.DeletionTimestamp != nil {
+		return ctrl.Result{}, r.deleteRoleBinding(ctx, namespace.Name)
+	}
+
+	if err := r.ensureClusterRole(ctx); err != nil {
+		log.Error(err, "unable to create tenant ClusterRole")
+		return ctrl.Result{}, err
+	}
+
+	roleBinding := &rbacv1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      TenantRoleBindingName,
+			Namespace: namespace.Name,
+		},
+	}
+	if _, err := controllerutil.CreateOrUpdate(ctx, r.Client, roleBinding, func() error {
+		// a RoleBinding of the same name created by someone else is not taken over
+		if !roleBinding.CreationTimestamp.IsZero() && !isManagedByOperator(roleBinding) {
+			return errors.NewAlreadyExists(rbacv1.Resource("rolebindings"), TenantRoleBindingName)
+		}
+		if roleBinding.Labels == nil {
+			roleBinding.Labels = map[string]string{}
+		}
+		roleBinding.Labels[managedByLabel] = managedByValue
+		// the role of a RoleBinding is immutable, it is only set on creation
+		if roleBinding.RoleRef.Name == "" {
+			roleBinding.RoleRef = rbacv1.RoleRef{
+				APIGroup: rbacv1.GroupName,
+				Kind:     "ClusterRole",
+				Name:     TenantClusterRoleName,
+			}
+		}
+		roleBinding.Subjects = tenantRBAC.SubjectsFor(namespace.Name)
+		return nil
+	}); err != nil {
+		log.Error(err, "unable to create tenant RoleBinding")
+		return ctrl.Result{}, err
+	}
+
+	return ctrl.Result{}, nil
+}
+
+// ensureClusterRole creates or updates the tenant ClusterRole, it is aggregated into the
+// built-in admin and edit roles so that namespace admins and editors can use NamespaceLabel too
+func (r *TenantRBACReconciler) ensureClusterRole(ctx context.Context) error {
+	clusterRole := &rbacv1.ClusterRole{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: TenantClusterRoleName,
+		},
+	}
+	_, err := controllerutil.CreateOrUpdate(ctx, r.Client, clusterRole, func() error {
+		if clusterRole.Labels == nil {
+			clusterRole.Labels = map[string]string{}
+		}
+		clusterRole.Labels[managedByLabel] = managedByValue
+		clusterRole.Labels["rbac.authorization.k8s.io/aggregate-to-admin"] = "true"
+		clusterRole.Labels["rbac.authorization.k8s.io/aggregate-to-edit"] = "true"
+		clusterRole.Rules = []rbacv1.PolicyRule{
+			{
+				APIGroups: []string{danaiov1alpha1.GroupVersion.Group},
+				Resources: []string{"namespacelabels"},
+				Verbs:     []string{"create", "delete", "get", "list", "patch", "update", "watch"},
+			},
+			{
+				APIGroups: []string{danaiov1alpha1.GroupVersion.Group},
+				Resources: []string{"namespacelabels/status"},
+				Verbs:     []string{"get"},
+			},
+			{
+				APIGroups: []string{danaiov1alpha1.GroupVersion.Group},
+				Resources: []string{"namespacelabelrevisions"},
+				Verbs:     []string{"get", "list", "watch"},
+			},
+		}
+		return nil
+	})
+
+	return err
+}
+
+// deleteRoleBinding removes the tenant RoleBinding from a namespace, RoleBindings of the
+// same name that were not created by the operator are left alone
+func (r *TenantRBACReconciler) deleteRoleBinding(ctx context.Context, namespace string) error {
+	roleBinding := &rbacv1.RoleBinding{}
+	if err := r.Get(ctx, types.NamespacedName{Name: TenantRoleBindingName, Namespace: namespace}, roleBinding); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+
+	if !isManagedByOperator(roleBinding) {
+		return nil
+	}
+
+	return client.IgnoreNotFound(r.Delete(ctx, roleBinding))
+}
+
+// isManagedByOperator reports whether the object carries the managed-by label of the operator
+func isManagedByOperator(obj client.Object) bool {
+	return obj.GetLabels()[managedByLabel] == managedByValue
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *TenantRBACReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("tenantrbac").
+		For(&v1.Namespace{}).
+		Watches(&source.Kind{Type: &configv1alpha1.NamespacelabelConfig{}}, handler.EnqueueRequestsFromMapFunc(r.findNamespacesForConfig)).
+		Watches(&source.Kind{Type: &rbacv1.RoleBinding{}}, handler.EnqueueRequestsFromMapFunc(r.findNamespaceForRoleBinding),
+			builder.WithPredicates(predicate.NewPredicateFuncs(isManagedByOperator))).
+		Complete(r)
+}
+
+// findNamespacesForConfig maps a NamespacelabelConfig event to reconcile requests for
+// every namespace, since the tenant selector may have changed
+func (r *TenantRBACReconciler) findNamespacesForConfig(config client.Object) []reconcile.Request {
+	namespaces := &v1.NamespaceList{}
+	if err := r.List(context.TODO(), namespaces); err != nil {
+		return []reconcile.Request{}
+	}
+
+	requests := make([]reconcile.Request, len(namespaces.Items))
+	for i, item := range namespaces.Items {
+		requests[i] = reconcile.Request{
+			NamespacedName: types.NamespacedName{
+				Name: item.GetName(),
+			},
+		}
+	}
+
+	return requests
+}
+
+// findNamespaceForRoleBinding maps a change of the tenant RoleBinding to a reconcile
+// request for its namespace, so that edits and deletions are reverted
+func (r *TenantRBACReconciler) findNamespaceForRoleBinding(roleBinding client.Object) []reconcile.Request {
+	if roleBinding.GetName() != TenantRoleBindingName {
+		return []reconcile.Request{}
+	}
+
+	return []reconcile.Request{{NamespacedName: types.NamespacedName{Name: roleBinding.GetNamespace()}}}
+}
@@ -0,0 +1,123 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	configv1alpha1 "home-assignment/apis/config/v1alpha1"
+)
+
+func generateTenantRoleBindingObject(namespace string, labels map[string]string) *rbacv1.RoleBinding {
+	return &rbacv1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      TenantRoleBindingName,
+			Namespace: namespace,
+			Labels:    labels,
+		},
+		RoleRef: rbacv1.RoleRef{
+			APIGroup: rbacv1.GroupName,
+			Kind:     "ClusterRole",
+			Name:     TenantClusterRoleName,
+		},
+	}
+}
+
+func TestTenantRBACReconciler(t *testing.T) {
+	g := NewGomegaWithT(t)
+	RegisterFailHandler(ginkgo.Fail)
+
+	config := &configv1alpha1.NamespacelabelConfig{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: configv1alpha1.ClusterConfigName,
+		},
+		Spec: configv1alpha1.NamespacelabelConfigSpec{
+			TenantRBAC: configv1alpha1.NamespacelabelConfigTenantRBAC{
+				NamespaceSelector: &metav1.LabelSelector{
+					MatchLabels: map[string]string{"tenant": "true"},
+				},
+				Subjects: []rbacv1.Subject{
+					{Kind: rbacv1.GroupKind, APIGroup: rbacv1.GroupName, Name: "$(NAMESPACE)-admins"},
+					{Kind: rbacv1.ServiceAccountKind, Name: "deployer"},
+				},
+			},
+		},
+	}
+
+	managed := map[string]string{managedByLabel: managedByValue}
+	obj := []client.Object{
+		config,
+		generateTenantNamespaceObject("team-a", map[string]string{"tenant": "true"}),
+		// team-b stopped being a tenant namespace after its RoleBinding was created
+		generateTenantNamespaceObject("team-b", map[string]string{"tenant": "false"}),
+		generateTenantRoleBindingObject("team-b", managed),
+		// team-c has a RoleBinding of the same name that the operator did not create
+		generateTenantNamespaceObject("team-c", nil),
+		generateTenantRoleBindingObject("team-c", nil),
+	}
+
+	cl, s, err := setupClient(obj)
+	if err != nil {
+		t.Fatalf("Unable to add to scheme: %v", err)
+	}
+
+	// create a TenantRBACReconciler object with the scheme and fake client
+	r := &TenantRBACReconciler{cl, s}
+
+	for _, name := range []string{"team-a", "team-b", "team-c"} {
+		if _, err := r.Reconcile(context.TODO(), reconcile.Request{NamespacedName: types.NamespacedName{Name: name}}); err != nil {
+			t.Fatalf("Unable to reconcile %s: %v", name, err)
+		}
+	}
+
+	// check that the ClusterRole is generated and aggregated into admin and edit
+	clusterRole := &rbacv1.ClusterRole{}
+	if err := r.Get(context.TODO(), types.NamespacedName{Name: TenantClusterRoleName}, clusterRole); err != nil {
+		t.Fatalf("get: (%v)", err)
+	}
+	g.Expect(clusterRole.Labels).To(HaveKeyWithValue("rbac.authorization.k8s.io/aggregate-to-admin", "true"))
+	g.Expect(clusterRole.Labels).To(HaveKeyWithValue("rbac.authorization.k8s.io/aggregate-to-edit", "true"))
+	g.Expect(clusterRole.Rules).NotTo(BeEmpty())
+
+	// check that the tenant namespace is bound with the subjects of the config
+	roleBinding := &rbacv1.RoleBinding{}
+	if err := r.Get(context.TODO(), types.NamespacedName{Name: TenantRoleBindingName, Namespace: "team-a"}, roleBinding); err != nil {
+		t.Fatalf("get: (%v)", err)
+	}
+	g.Expect(roleBinding.RoleRef.Name).To(Equal(TenantClusterRoleName))
+	g.Expect(roleBinding.Subjects).To(Equal([]rbacv1.Subject{
+		{Kind: rbacv1.GroupKind, APIGroup: rbacv1.GroupName, Name: "team-a-admins"},
+		{Kind: rbacv1.ServiceAccountKind, Name: "deployer", Namespace: "team-a"},
+	}))
+
+	// check that the binding is removed from the namespace that no longer matches
+	err = r.Get(context.TODO(), types.NamespacedName{Name: TenantRoleBindingName, Namespace: "team-b"}, roleBinding)
+	g.Expect(errors.IsNotFound(err)).To(BeTrue())
+
+	// check that the binding not created by the operator is left alone
+	g.Expect(r.Get(context.TODO(), types.NamespacedName{Name: TenantRoleBindingName, Namespace: "team-c"}, roleBinding)).To(Succeed())
+}
@@ -18,6 +18,7 @@ package main
 
 import (
 	"flag"
+	"fmt"
 	"os"
 
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
@@ -31,6 +32,7 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 
+	configv1alpha1 "home-assignment/apis/config/v1alpha1"
 	danaiov1alpha1 "home-assignment/apis/namespacelabel/v1alpha1"
 	"home-assignment/controllers"
 	//+kubebuilder:scaffold:imports
@@ -44,6 +46,7 @@ var (
 func init() {
 	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
 	utilruntime.Must(danaiov1alpha1.AddToScheme(scheme))
+	utilruntime.Must(configv1alpha1.AddToScheme(scheme))
 	//+kubebuilder:scaffold:scheme
 }
 
@@ -78,16 +81,64 @@ func main() {
 	}
 
 	if err = (&controllers.NamespaceLabelReconciler{
+		Client:   mgr.GetClient(),
+		Scheme:   mgr.GetScheme(),
+		Recorder: mgr.GetEventRecorderFor("namespacelabel-controller"),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "NamespaceLabel")
+		os.Exit(1)
+	}
+	if err = (&controllers.ClusterNamespaceLabelReconciler{
 		Client: mgr.GetClient(),
 		Scheme: mgr.GetScheme(),
 	}).SetupWithManager(mgr); err != nil {
-		setupLog.Error(err, "unable to create controller", "controller", "NamespaceLabel")
+		setupLog.Error(err, "unable to create controller", "controller", "ClusterNamespaceLabel")
+		os.Exit(1)
+	}
+	if err = (&controllers.TenantRBACReconciler{
+		Client: mgr.GetClient(),
+		Scheme: mgr.GetScheme(),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "TenantRBAC")
+		os.Exit(1)
+	}
+	if err = (&controllers.InheritanceReconciler{
+		Client: mgr.GetClient(),
+		Scheme: mgr.GetScheme(),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "Inheritance")
 		os.Exit(1)
 	}
-	if err = (&danaiov1alpha1.NamespaceLabel{}).SetupWebhookWithManager(mgr); err != nil {
+	// the manager identity is passed in through the downward api, its own updates of namespaces
+	// and NamespaceLabels are always allowed.
+	// Outside the cluster, e.g. with make run, it is left empty so no made-up service account is allowed
+	var managerUsername string
+	podNamespace, serviceAccountName := os.Getenv("POD_NAMESPACE"), os.Getenv("SERVICE_ACCOUNT_NAME")
+	if podNamespace != "" && serviceAccountName != "" {
+		managerUsername = fmt.Sprintf("system:serviceaccount:%s:%s", podNamespace, serviceAccountName)
+	} else {
+		setupLog.Info("POD_NAMESPACE and SERVICE_ACCOUNT_NAME are not set, the updates of the manager are validated like any other user")
+	}
+	if err = (&danaiov1alpha1.NamespaceLabel{}).SetupWebhookWithManager(mgr, managerUsername); err != nil {
 		setupLog.Error(err, "unable to create webhook", "webhook", "NamespaceLabel")
 		os.Exit(1)
 	}
+	if err = (&danaiov1alpha1.ClusterNamespaceLabel{}).SetupWebhookWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create webhook", "webhook", "ClusterNamespaceLabel")
+		os.Exit(1)
+	}
+	if err = (&danaiov1alpha1.NamespaceLabelRevision{}).SetupWebhookWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create webhook", "webhook", "NamespaceLabelRevision")
+		os.Exit(1)
+	}
+	if err = (&configv1alpha1.NamespacelabelConfig{}).SetupWebhookWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create webhook", "webhook", "NamespacelabelConfig")
+		os.Exit(1)
+	}
+	if err = danaiov1alpha1.SetupNamespaceWebhookWithManager(mgr, managerUsername); err != nil {
+		setupLog.Error(err, "unable to create webhook", "webhook", "Namespace")
+		os.Exit(1)
+	}
 	// +kubebuilder:scaffold:builder
 
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {